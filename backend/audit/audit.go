@@ -0,0 +1,161 @@
+// Package audit records changes to portfolios, stocks and covered calls and
+// publishes the portfolio events they describe. Request handlers and
+// background jobs both write through it, so every change is audited the same
+// way.
+package audit
+
+import (
+	"deltra-backend/config"
+	"deltra-backend/events"
+	"deltra-backend/models"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Actor is whoever made a change. The zero Actor is the system, for changes
+// made by background jobs.
+type Actor struct {
+	UserID    string
+	APIKeyID  string
+	RequestID string
+}
+
+// System marks changes that no user made directly.
+var System = Actor{}
+
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// ignoredFields change on every save and would drown out real edits.
+var ignoredFields = map[string]bool{"updated_at": true}
+
+// NewEntity returns an empty model for an audited entity type, or nil.
+func NewEntity(entityType string) any {
+	switch entityType {
+	case "portfolio":
+		return &models.Portfolio{}
+	case "stock":
+		return &models.Stock{}
+	case "covered_call":
+		return &models.CoveredCall{}
+	}
+	return nil
+}
+
+// Target returns the entity type, id, portfolio and owning user of an
+// audited model.
+func Target(entity any) (string, string, string, string) {
+	switch e := entity.(type) {
+	case *models.Portfolio:
+		return "portfolio", e.ID, e.ID, e.UserID
+	case *models.Stock:
+		return "stock", e.ID, e.PortfolioID, e.UserID
+	case *models.CoveredCall:
+		return "covered_call", e.ID, e.PortfolioID, e.UserID
+	}
+	return "", "", "", ""
+}
+
+// State captures the stored columns of a model, keyed by JSON name, and
+// leaves out associations and computed fields.
+func State(entity any) map[string]any {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil
+	}
+	var state map[string]any
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: config.DB}
+	if err := stmt.Parse(entity); err != nil {
+		return state
+	}
+	for _, field := range stmt.Schema.Fields {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.DBName == "" {
+			delete(state, name)
+			continue
+		}
+		// Postgres keeps microseconds and may hand back another zone, so
+		// normalize times to compare in-memory and reloaded values.
+		if value, ok := state[name].(string); ok && field.DataType == schema.Time {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				state[name] = t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return state
+}
+
+func Changes(before, after map[string]any) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	for key, value := range after {
+		if !ignoredFields[key] && !reflect.DeepEqual(before[key], value) {
+			changes[key] = FieldChange{From: before[key], To: value}
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok && !ignoredFields[key] && value != nil {
+			changes[key] = FieldChange{From: value, To: nil}
+		}
+	}
+	return changes
+}
+
+func NewEvent(actor Actor, action string, entity any, before, after map[string]any) models.AuditEvent {
+	entityType, entityID, portfolioID, ownerID := Target(entity)
+
+	event := models.AuditEvent{
+		UserID:      ownerID,
+		PortfolioID: portfolioID,
+		RequestID:   actor.RequestID,
+		Action:      action,
+		EntityType:  entityType,
+		EntityID:    entityID,
+	}
+	if actor.UserID != "" {
+		event.ActorID = &actor.UserID
+	}
+	if actor.APIKeyID != "" {
+		event.APIKeyID = &actor.APIKeyID
+	}
+	if before != nil {
+		event.Before, _ = models.NewJSON(before)
+	}
+	if after != nil {
+		event.After, _ = models.NewJSON(after)
+	}
+	event.Changes, _ = models.NewJSON(Changes(before, after))
+
+	return event
+}
+
+// Record appends an audit event for a change made in tx. Pass the state
+// captured before the change, or nil for creates. The returned change must
+// be broadcast once tx commits.
+func Record(tx *gorm.DB, actor Actor, action string, entity any, before map[string]any) (events.Change, error) {
+	var after map[string]any
+	if action != models.AuditDelete {
+		after = State(entity)
+	}
+
+	return Create(tx, NewEvent(actor, action, entity, before, after))
+}
+
+// Create stores an audit event and publishes the portfolio event it
+// describes, so subscribers see exactly what was audited.
+func Create(tx *gorm.DB, event models.AuditEvent) (events.Change, error) {
+	if err := tx.Create(&event).Error; err != nil {
+		return events.Change{}, err
+	}
+	return events.Publish(tx, events.FromAudit(event))
+}
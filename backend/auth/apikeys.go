@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/rand"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix marks a bearer credential as an API key rather than a JWT.
+const APIKeyPrefix = "dk_"
+
+// apiKeyTouchInterval limits last_used_at writes for busy scripts.
+const apiKeyTouchInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// CreateAPIKey stores a new key and returns it along with the raw secret,
+// which is only ever shown once.
+func CreateAPIKey(userID, name, scope string, expiresAt *time.Time) (models.APIKey, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return models.APIKey{}, "", err
+	}
+	raw := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:len(APIKeyPrefix)+8],
+		KeyHash:   HashToken(raw),
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+	}
+	if err := config.DB.Create(&key).Error; err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	return key, raw, nil
+}
+
+func AuthenticateAPIKey(raw string) (Principal, error) {
+	var key models.APIKey
+	if err := config.DB.Where("key_hash = ?", HashToken(raw)).First(&key).Error; err != nil {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return Principal{}, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		config.DB.Model(&key).Update("last_used_at", now)
+	}
+
+	principal := Principal{
+		UserID:   key.UserID,
+		APIKeyID: key.ID,
+		Scope:    key.Scope,
+	}
+	if key.ExpiresAt != nil {
+		principal.ExpiresAt = *key.ExpiresAt
+	}
+	return principal, nil
+}
+
+// APIKeyActive reports whether the key hasn't been revoked or expired.
+func APIKeyActive(keyID string) bool {
+	var key models.APIKey
+	if err := config.DB.Select("id", "revoked_at", "expires_at").
+		Where("id = ?", keyID).
+		First(&key).Error; err != nil {
+		return false
+	}
+	return key.Active(time.Now())
+}
+
+func RevokeAPIKey(userID, keyID string) error {
+	var key models.APIKey
+	if err := config.DB.Where("id = ? AND user_id = ?", keyID, userID).First(&key).Error; err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	return config.DB.Save(&key).Error
+}
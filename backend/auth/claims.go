@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"deltra-backend/models"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrWrongTokenType = errors.New("token is not an access token")
+	ErrMissingSubject = errors.New("token has no subject")
+	ErrLegacyToken    = errors.New("legacy tokens are no longer accepted")
+)
+
+type AccessClaims struct {
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Type      string `json:"type,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Principal is the authenticated caller behind a request. APIKeyID and
+// Scope are only set when the caller used an API key. Legacy marks tokens
+// admitted through the legacy window, which carry no session. ExpiresAt is
+// when the credential lapses; zero for API keys without an expiry.
+type Principal struct {
+	UserID    string
+	Email     string
+	Name      string
+	SessionID string
+	APIKeyID  string
+	Scope     string
+	Legacy    bool
+	ExpiresAt time.Time
+}
+
+// Active reports whether the credential behind p still holds: it hasn't
+// expired and its session or API key hasn't been revoked. Connections that
+// outlive the request's own check call it periodically.
+func (p Principal) Active(now time.Time) bool {
+	if !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt) {
+		return false
+	}
+	if p.SessionID != "" && !SessionActive(p.SessionID) {
+		return false
+	}
+	if p.APIKeyID != "" && !APIKeyActive(p.APIKeyID) {
+		return false
+	}
+	return true
+}
+
+func (p Principal) CanWrite() bool {
+	return p.APIKeyID == "" || p.Scope == models.APIKeyScopeReadWrite
+}
+
+func Issuer() string {
+	return envOr("JWT_ISSUER", "deltra-api")
+}
+
+func Audience() string {
+	return envOr("JWT_AUDIENCE", "deltra")
+}
+
+// ParseAccessToken verifies a bearer token and returns its principal. Every
+// token must be an access token naming the expected issuer and audience.
+// Tokens carrying a kid were minted by this server and name ours. Tokens
+// without one are legacy HS256 tokens from the frontend auth routes; they
+// are only accepted while the legacy window configured in LoadKeys is open,
+// must name the issuer and audience configured for it, and may omit the type
+// because those routes never set it on access tokens.
+func ParseAccessToken(tokenString string) (Principal, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, Keyfunc,
+		jwt.WithValidMethods(ValidMethods()),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	_, hasKID := token.Header["kid"]
+	issuer, audience := Issuer(), Audience()
+	if !hasKID {
+		var open bool
+		if issuer, audience, open = legacyClaims(); !open {
+			return Principal{}, ErrLegacyToken
+		}
+	}
+
+	if claims.Type != TokenTypeAccess && (hasKID || claims.Type != "") {
+		return Principal{}, ErrWrongTokenType
+	}
+	if claims.Issuer != issuer {
+		return Principal{}, jwt.ErrTokenInvalidIssuer
+	}
+	if !slices.Contains(claims.Audience, audience) {
+		return Principal{}, jwt.ErrTokenInvalidAudience
+	}
+
+	if claims.Subject == "" {
+		return Principal{}, ErrMissingSubject
+	}
+
+	return Principal{
+		UserID:    claims.Subject,
+		Email:     claims.Email,
+		Name:      claims.Name,
+		SessionID: claims.SessionID,
+		Legacy:    !hasKID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
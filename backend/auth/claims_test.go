@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func loadTestKeys(t *testing.T, env map[string]string) {
+	t.Helper()
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("JWT_LEGACY_UNTIL", "")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	if err := LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func accessClaims(modify func(*AccessClaims)) *AccessClaims {
+	now := time.Now()
+	claims := &AccessClaims{
+		SessionID: "session-1",
+		Type:      TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    Issuer(),
+			Audience:  jwt.ClaimStrings{Audience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	if modify != nil {
+		modify(claims)
+	}
+	return claims
+}
+
+// legacyToken signs claims the way the old frontend routes did: HS256 with
+// the shared secret and no kid.
+func legacyToken(t *testing.T, claims *AccessClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestParseAccessToken(t *testing.T) {
+	loadTestKeys(t, nil)
+
+	tests := []struct {
+		name    string
+		modify  func(*AccessClaims)
+		wantErr error
+	}{
+		{name: "valid"},
+		{
+			name:    "refresh token",
+			modify:  func(c *AccessClaims) { c.Type = TokenTypeRefresh },
+			wantErr: ErrWrongTokenType,
+		},
+		{
+			name:    "missing type",
+			modify:  func(c *AccessClaims) { c.Type = "" },
+			wantErr: ErrWrongTokenType,
+		},
+		{
+			name:    "wrong issuer",
+			modify:  func(c *AccessClaims) { c.Issuer = "https://accounts.google.com" },
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:    "wrong audience",
+			modify:  func(c *AccessClaims) { c.Audience = jwt.ClaimStrings{"someone-else"} },
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:    "expired",
+			modify:  func(c *AccessClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) },
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:    "missing subject",
+			modify:  func(c *AccessClaims) { c.Subject = "" },
+			wantErr: ErrMissingSubject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := SignToken(accessClaims(tt.modify))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := ParseAccessToken(token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseAccessToken() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAccessToken() error = %v", err)
+			}
+			if principal.UserID != "user-1" || principal.SessionID != "session-1" || principal.Legacy {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+			if principal.ExpiresAt.IsZero() {
+				t.Error("ExpiresAt not taken from the token")
+			}
+		})
+	}
+}
+
+func TestParseAccessTokenLegacy(t *testing.T) {
+	legacy := func(c *AccessClaims) {
+		c.Type = ""
+		c.SessionID = ""
+		c.Issuer = "https://accounts.google.com"
+		c.Audience = jwt.ClaimStrings{"google-client"}
+	}
+	window := map[string]string{
+		"JWT_LEGACY_ISSUER":   "https://accounts.google.com",
+		"JWT_LEGACY_AUDIENCE": "google-client",
+	}
+
+	t.Run("rejected without a window", func(t *testing.T) {
+		loadTestKeys(t, nil)
+		if _, err := ParseAccessToken(legacyToken(t, accessClaims(legacy))); err == nil {
+			t.Fatal("legacy token accepted without a legacy window")
+		}
+	})
+
+	t.Run("rejected after the window closes", func(t *testing.T) {
+		env := map[string]string{"JWT_LEGACY_UNTIL": time.Now().Add(-time.Hour).Format(time.RFC3339)}
+		for key, value := range window {
+			env[key] = value
+		}
+		loadTestKeys(t, env)
+		if _, err := ParseAccessToken(legacyToken(t, accessClaims(legacy))); err == nil {
+			t.Fatal("legacy token accepted after the window closed")
+		}
+	})
+
+	open := map[string]string{"JWT_LEGACY_UNTIL": time.Now().Add(time.Hour).Format(time.RFC3339)}
+	for key, value := range window {
+		open[key] = value
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*AccessClaims)
+		wantErr error
+	}{
+		{name: "accepted in the window", modify: legacy},
+		{
+			name: "refresh token",
+			modify: func(c *AccessClaims) {
+				legacy(c)
+				c.Type = TokenTypeRefresh
+			},
+			wantErr: ErrWrongTokenType,
+		},
+		{
+			name: "our issuer is not the legacy issuer",
+			modify: func(c *AccessClaims) {
+				legacy(c)
+				c.Issuer = Issuer()
+			},
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			modify: func(c *AccessClaims) {
+				legacy(c)
+				c.Audience = jwt.ClaimStrings{Audience()}
+			},
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadTestKeys(t, open)
+
+			principal, err := ParseAccessToken(legacyToken(t, accessClaims(tt.modify)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseAccessToken() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAccessToken() error = %v", err)
+			}
+			if !principal.Legacy || principal.UserID != "user-1" {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestLoadKeysLegacyWindowNeedsClaims(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", "HS256")
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("JWT_LEGACY_UNTIL", time.Now().Add(time.Hour).Format(time.RFC3339))
+	t.Setenv("JWT_LEGACY_ISSUER", "")
+	t.Setenv("JWT_LEGACY_AUDIENCE", "")
+
+	if err := LoadKeys(); err == nil {
+		t.Fatal("LoadKeys() accepted a legacy window without an issuer and audience")
+	}
+}
+
+func TestPrincipalActiveExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      bool
+	}{
+		{name: "no expiry", want: true},
+		{name: "not yet expired", expiresAt: now.Add(time.Minute), want: true},
+		{name: "expired", expiresAt: now, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal := Principal{UserID: "user-1", ExpiresAt: tt.expiresAt}
+			if got := principal.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
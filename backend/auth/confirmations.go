@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const ConfirmationTokenTTL = 10 * time.Minute
+
+var ErrInvalidConfirmation = errors.New("invalid confirmation token")
+
+type confirmationClaims struct {
+	Purpose string `json:"purpose"`
+	Type    string `json:"type"`
+	jwt.RegisteredClaims
+}
+
+// IssueConfirmationToken returns a short-lived token proving the user asked
+// for a destructive action. Its type keeps it from being used as an access
+// token.
+func IssueConfirmationToken(userID, purpose string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ConfirmationTokenTTL)
+
+	signed, err := SignToken(confirmationClaims{
+		Purpose: purpose,
+		Type:    "confirmation",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer(),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{Audience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	return signed, expiresAt, err
+}
+
+func VerifyConfirmationToken(tokenString, userID, purpose string) error {
+	claims := &confirmationClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, Keyfunc,
+		jwt.WithValidMethods(ValidMethods()),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(Issuer()),
+		jwt.WithAudience(Audience()),
+		jwt.WithSubject(userID),
+	); err != nil {
+		return ErrInvalidConfirmation
+	}
+
+	if claims.Type != "confirmation" || claims.Purpose != purpose {
+		return ErrInvalidConfirmation
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clockSkew = time.Minute
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+	ErrMissingNonce    = errors.New("nonce is required")
+)
+
+type IdentityProvider struct {
+	Name      string
+	Issuers   []string
+	Audiences []string
+	Keys      KeySource
+}
+
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+var (
+	providersOnce sync.Once
+	providersMu   sync.RWMutex
+	providers     = map[string]IdentityProvider{}
+)
+
+func loadIdentityProviders() {
+	google := IdentityProvider{
+		Name:      "google",
+		Issuers:   []string{"https://accounts.google.com", "accounts.google.com"},
+		Audiences: splitList(os.Getenv("GOOGLE_CLIENT_IDS")),
+		Keys:      NewJWKSCache(envOr("GOOGLE_JWKS_URL", "https://www.googleapis.com/oauth2/v3/certs"), time.Hour),
+	}
+	apple := IdentityProvider{
+		Name:      "apple",
+		Issuers:   []string{"https://appleid.apple.com"},
+		Audiences: splitList(os.Getenv("APPLE_CLIENT_IDS")),
+		Keys:      NewJWKSCache(envOr("APPLE_JWKS_URL", "https://appleid.apple.com/auth/keys"), time.Hour),
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	for _, provider := range []IdentityProvider{google, apple} {
+		if _, ok := providers[provider.Name]; !ok {
+			providers[provider.Name] = provider
+		}
+	}
+}
+
+// RegisterIdentityProvider replaces a provider's configuration, e.g. with a
+// FakeIdentityProvider in tests.
+func RegisterIdentityProvider(provider IdentityProvider) {
+	providersOnce.Do(loadIdentityProviders)
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Name] = provider
+}
+
+// VerifyIDToken checks an OpenID Connect ID token against the provider's
+// published keys and returns the identity from its verified claims. The
+// token must carry the nonce the client generated for this sign-in, either
+// raw or SHA-256 hashed as Apple does, so a captured token can't be replayed.
+func VerifyIDToken(ctx context.Context, providerName, rawToken, nonce string) (Identity, error) {
+	providersOnce.Do(loadIdentityProviders)
+
+	providersMu.RLock()
+	provider, ok := providers[providerName]
+	providersMu.RUnlock()
+	if !ok {
+		return Identity{}, ErrUnknownProvider
+	}
+	if len(provider.Audiences) == 0 {
+		return Identity{}, fmt.Errorf("no client IDs configured for %s", providerName)
+	}
+	if nonce == "" {
+		return Identity{}, ErrMissingNonce
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.Keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	issuer, _ := claims.GetIssuer()
+	if !slices.Contains(provider.Issuers, issuer) {
+		return Identity{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, issuer)
+	}
+
+	audiences, _ := claims.GetAudience()
+	if !slices.ContainsFunc(audiences, func(aud string) bool { return slices.Contains(provider.Audiences, aud) }) {
+		return Identity{}, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return Identity{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	hashed := sha256.Sum256([]byte(nonce))
+	if tokenNonce != nonce && tokenNonce != hex.EncodeToString(hashed[:]) {
+		return Identity{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	identity := Identity{
+		Provider: provider.Name,
+		Subject:  subject,
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Picture, _ = claims["picture"].(string)
+
+	// Apple sends email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
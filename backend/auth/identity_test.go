@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newFakeProvider(t *testing.T, name string) *FakeIdentityProvider {
+	t.Helper()
+	fake, err := NewFakeIdentityProvider(name, "https://issuer.example", "client-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterIdentityProvider(fake.Provider())
+	return fake
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	fake := newFakeProvider(t, "fake")
+	other, err := NewFakeIdentityProvider("other", "https://issuer.example", "client-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashedNonce := sha256.Sum256([]byte("nonce-1"))
+
+	tests := []struct {
+		name    string
+		signer  *FakeIdentityProvider
+		extra   jwt.MapClaims
+		noNonce bool
+		wantErr error
+		check   func(t *testing.T, identity Identity)
+	}{
+		{
+			name:   "valid",
+			signer: fake,
+			extra:  jwt.MapClaims{"name": "Ada"},
+			check: func(t *testing.T, identity Identity) {
+				if identity.Provider != "fake" || identity.Subject != "subject-1" || identity.Email != "ada@example.com" {
+					t.Errorf("unexpected identity %+v", identity)
+				}
+				if !identity.EmailVerified || identity.Name != "Ada" {
+					t.Errorf("claims not copied: %+v", identity)
+				}
+			},
+		},
+		{
+			name:   "email_verified as a string",
+			signer: fake,
+			extra:  jwt.MapClaims{"email_verified": "false"},
+			check: func(t *testing.T, identity Identity) {
+				if identity.EmailVerified {
+					t.Error(`email_verified "false" was treated as verified`)
+				}
+			},
+		},
+		{
+			name:   "hashed nonce",
+			signer: fake,
+			extra:  jwt.MapClaims{"nonce": hex.EncodeToString(hashedNonce[:])},
+		},
+		{
+			name:    "nonce mismatch",
+			signer:  fake,
+			extra:   jwt.MapClaims{"nonce": "nonce-2"},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "token without a nonce",
+			signer:  fake,
+			extra:   jwt.MapClaims{"nonce": nil},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "no nonce sent",
+			signer:  fake,
+			noNonce: true,
+			wantErr: ErrMissingNonce,
+		},
+		{
+			name:    "wrong audience",
+			signer:  fake,
+			extra:   jwt.MapClaims{"aud": "someone-else"},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "wrong issuer",
+			signer:  fake,
+			extra:   jwt.MapClaims{"iss": "https://evil.example"},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "expired",
+			signer:  fake,
+			extra:   jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "missing subject",
+			signer:  fake,
+			extra:   jwt.MapClaims{"sub": ""},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name:    "signed by an unknown key",
+			signer:  other,
+			wantErr: ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extra := jwt.MapClaims{"nonce": "nonce-1"}
+			maps.Copy(extra, tt.extra)
+			token, err := tt.signer.SignIDToken("subject-1", "ada@example.com", extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nonce := "nonce-1"
+			if tt.noNonce {
+				nonce = ""
+			}
+			identity, err := VerifyIDToken(context.Background(), "fake", token, nonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("VerifyIDToken() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyIDToken() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, identity)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenUnknownProvider(t *testing.T) {
+	if _, err := VerifyIDToken(context.Background(), "nobody", "token", "nonce-1"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("VerifyIDToken() error = %v, want %v", err, ErrUnknownProvider)
+	}
+}
+
+func TestFakeIdentityProviderJWKSFile(t *testing.T) {
+	fake, err := NewFakeIdentityProvider("file", "https://issuer.example", "client-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := t.TempDir() + "/jwks.json"
+	if err := fake.WriteJWKS(path); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := fake.Provider()
+	provider.Keys = NewJWKSCache("file://"+path, time.Hour)
+	RegisterIdentityProvider(provider)
+
+	token, err := fake.SignIDToken("subject-1", "ada@example.com", jwt.MapClaims{"nonce": "nonce-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyIDToken(context.Background(), "file", token, "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken() with keys read from disk: %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	signer any
+	public crypto.PublicKey
+}
+
+// keyRing holds the key new tokens are signed with plus every key still
+// accepted for verification. Rotating means adding the new key to
+// JWT_SIGNING_KEYS behind the current one, promoting it to the front once
+// every instance has it, and dropping the old key after tokens it signed
+// have expired.
+type keyRing struct {
+	signing *signingKey
+	byKID   map[string]*signingKey
+	legacy  *signingKey
+	window  *legacyWindow
+}
+
+// legacyWindow admits tokens minted without a kid by the frontend's old auth
+// routes, which copy the identity provider's iss and aud. It is closed unless
+// configured, and closes for good at until.
+type legacyWindow struct {
+	issuer   string
+	audience string
+	until    time.Time
+}
+
+func (w *legacyWindow) open(now time.Time) bool {
+	return w != nil && now.Before(w.until)
+}
+
+var ring atomic.Pointer[keyRing]
+
+// LoadKeys reads signing configuration from the environment:
+//
+//	JWT_ALGORITHM         HS256 (default), RS256, ES256 or EdDSA
+//	JWT_SECRET            HMAC signing secret
+//	JWT_PREVIOUS_SECRETS  comma-separated HMAC secrets still accepted
+//	JWT_SIGNING_KEYS      comma-separated PEM private key files
+//	JWT_ALLOW_HS256       accept HMAC tokens while signing asymmetrically
+//	JWT_LEGACY_UNTIL      accept legacy tokens without a kid until this
+//	                      RFC 3339 time, when they name
+//	JWT_LEGACY_ISSUER     and
+//	JWT_LEGACY_AUDIENCE
+func LoadKeys() error {
+	algorithm := envOr("JWT_ALGORITHM", "HS256")
+	loaded := &keyRing{byKID: map[string]*signingKey{}}
+
+	if until := os.Getenv("JWT_LEGACY_UNTIL"); until != "" {
+		deadline, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return fmt.Errorf("JWT_LEGACY_UNTIL: %w", err)
+		}
+		window := &legacyWindow{
+			issuer:   os.Getenv("JWT_LEGACY_ISSUER"),
+			audience: os.Getenv("JWT_LEGACY_AUDIENCE"),
+			until:    deadline,
+		}
+		if window.issuer == "" || window.audience == "" {
+			return errors.New("JWT_LEGACY_UNTIL needs JWT_LEGACY_ISSUER and JWT_LEGACY_AUDIENCE")
+		}
+		loaded.window = window
+	}
+
+	acceptHMAC := algorithm == "HS256" || os.Getenv("JWT_ALLOW_HS256") == "true"
+	if acceptHMAC {
+		var secrets []string
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			secrets = append(secrets, secret)
+		}
+		secrets = append(secrets, splitList(os.Getenv("JWT_PREVIOUS_SECRETS"))...)
+		for i, secret := range secrets {
+			key := &signingKey{
+				kid:    hmacKID(secret),
+				method: jwt.SigningMethodHS256,
+				signer: []byte(secret),
+			}
+			loaded.byKID[key.kid] = key
+			// Tokens minted without a kid are checked against the current secret.
+			if i == 0 && os.Getenv("JWT_SECRET") != "" {
+				loaded.legacy = key
+			}
+		}
+	}
+
+	for _, path := range splitList(os.Getenv("JWT_SIGNING_KEYS")) {
+		key, err := loadPrivateKey(path)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", path, err)
+		}
+		loaded.byKID[key.kid] = key
+		// The first key matching JWT_ALGORITHM signs; the rest only verify.
+		if loaded.signing == nil && key.method.Alg() == algorithm {
+			loaded.signing = key
+		}
+	}
+
+	if algorithm == "HS256" {
+		loaded.signing = loaded.legacy
+	}
+	if loaded.signing == nil {
+		if algorithm == "HS256" {
+			return ErrMissingSecret
+		}
+		return fmt.Errorf("no %s key in JWT_SIGNING_KEYS", algorithm)
+	}
+
+	ring.Store(loaded)
+	return nil
+}
+
+func currentRing() (*keyRing, error) {
+	if loaded := ring.Load(); loaded != nil {
+		return loaded, nil
+	}
+	if err := LoadKeys(); err != nil {
+		return nil, err
+	}
+	return ring.Load(), nil
+}
+
+func SignToken(claims jwt.Claims) (string, error) {
+	keys, err := currentRing()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(keys.signing.method, claims)
+	token.Header["kid"] = keys.signing.kid
+	return token.SignedString(keys.signing.signer)
+}
+
+// Keyfunc selects the verification key by kid and refuses tokens whose alg
+// doesn't match the key, so an RSA public key can never be used as an HMAC
+// secret.
+func Keyfunc(token *jwt.Token) (interface{}, error) {
+	keys, err := currentRing()
+	if err != nil {
+		return nil, err
+	}
+
+	var key *signingKey
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = keys.byKID[kid]
+	} else if keys.window.open(time.Now()) {
+		key = keys.legacy
+	}
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	if key.public != nil {
+		return key.public, nil
+	}
+	return key.signer, nil
+}
+
+// legacyClaims returns the issuer and audience legacy tokens must name, and
+// false once the legacy window is closed or was never opened.
+func legacyClaims() (string, string, bool) {
+	keys, err := currentRing()
+	if err != nil || !keys.window.open(time.Now()) {
+		return "", "", false
+	}
+	return keys.window.issuer, keys.window.audience, true
+}
+
+func ValidMethods() []string {
+	keys, err := currentRing()
+	if err != nil {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var methods []string
+	for _, key := range keys.byKID {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// PublicJWKS lists the asymmetric verification keys. HMAC secrets are never
+// published.
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	keys, err := currentRing()
+	if err != nil {
+		return set
+	}
+
+	for _, key := range keys.byKID {
+		if key.public == nil {
+			continue
+		}
+		jwk, err := publicJWK(key.kid, key.method.Alg(), key.public)
+		if err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func loadPrivateKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{signer: private}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+		key.public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 EC keys are supported")
+		}
+		key.method = jwt.SigningMethodES256
+		key.public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.public = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	jwk, err := publicJWK("", key.method.Alg(), key.public)
+	if err != nil {
+		return nil, err
+	}
+	key.kid = jwk.Thumbprint()
+
+	return key, nil
+}
+
+func publicJWK(kid, alg string, public crypto.PublicKey) (JWK, error) {
+	jwk := JWK{Kid: kid, Alg: alg, Use: "sig"}
+
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(bigEndian(k.E))
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	default:
+		return jwk, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	return jwk, nil
+}
+
+// Thumbprint is the RFC 7638 SHA-256 thumbprint, used as a stable kid.
+func (k JWK) Thumbprint() string {
+	var members map[string]string
+	switch k.Kty {
+	case "RSA":
+		members = map[string]string{"e": k.E, "kty": k.Kty, "n": k.N}
+	case "EC":
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X, "y": k.Y}
+	default:
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X}
+	}
+
+	// encoding/json sorts map keys, which is the canonical form required.
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func hmacKID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "hs-" + hex.EncodeToString(sum[:4])
+}
+
+func bigEndian(value int) []byte {
+	var out []byte
+	for value > 0 {
+		out = append([]byte{byte(value)}, out...)
+		value >>= 8
+	}
+	return out
+}
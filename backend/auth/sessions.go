@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func StartSession(user models.User, userAgent, ipAddress string) (TokenPair, error) {
+	now := time.Now()
+	session := models.Session{
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+	}
+
+	var pair TokenPair
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokens(tx, user, session)
+		return err
+	})
+
+	return pair, err
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already
+// rotated means it leaked, so the whole session is revoked.
+func Refresh(rawToken, userAgent, ipAddress string) (TokenPair, error) {
+	var pair TokenPair
+	reused := false
+	now := time.Now()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(rawToken)).
+			First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var session models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", token.SessionID).
+			First(&session).Error; err != nil {
+			return err
+		}
+
+		switch err := checkRefresh(token, session, now); {
+		case errors.Is(err, ErrRefreshTokenReused):
+			reused = true
+			return revoke(tx, &session, "refresh_token_reuse")
+		case err != nil:
+			return err
+		}
+
+		token.UsedAt = &now
+		if err := tx.Save(&token).Error; err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.UserAgent = userAgent
+		session.IPAddress = ipAddress
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = issueTokens(tx, user, session)
+		return err
+	})
+
+	if err == nil && reused {
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+	return pair, err
+}
+
+// checkRefresh decides whether a refresh token may be rotated. A token that
+// was already used reports ErrRefreshTokenReused, and the caller revokes the
+// session.
+func checkRefresh(token models.RefreshToken, session models.Session, now time.Time) error {
+	if session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if token.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if !now.Before(token.ExpiresAt) || !session.Active(now) {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func RevokeSession(userID, sessionID, reason string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+			return err
+		}
+		if session.RevokedAt != nil {
+			return nil
+		}
+		return revoke(tx, &session, reason)
+	})
+}
+
+func RevokeSessionByRefreshToken(rawToken, reason string) error {
+	var token models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", HashToken(rawToken)).First(&token).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+
+	var session models.Session
+	if err := config.DB.Where("id = ?", token.SessionID).First(&session).Error; err != nil {
+		return err
+	}
+
+	return RevokeSession(session.UserID, session.ID, reason)
+}
+
+// RevokeOtherSessions revokes every session of the user except
+// keepSessionID. Callers without a session pass "" to revoke them all.
+func RevokeOtherSessions(userID, keepSessionID, reason string) (int64, error) {
+	now := time.Now()
+	query := config.DB.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepSessionID != "" {
+		query = query.Where("id <> ?", keepSessionID)
+	}
+	result := query.Updates(map[string]any{"revoked_at": now, "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+func SessionActive(sessionID string) bool {
+	var session models.Session
+	if err := config.DB.Select("id", "revoked_at", "expires_at").
+		Where("id = ?", sessionID).
+		First(&session).Error; err != nil {
+		return false
+	}
+	return session.Active(time.Now())
+}
+
+func issueTokens(tx *gorm.DB, user models.User, session models.Session) (TokenPair, error) {
+	raw, hash, err := NewOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if err := tx.Create(&models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hash,
+		ExpiresAt: session.ExpiresAt,
+	}).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	accessToken, expiresAt, err := issueAccessToken(user, session.ID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresAt:    expiresAt,
+		SessionID:    session.ID,
+	}, nil
+}
+
+func revoke(tx *gorm.DB, session *models.Session, reason string) error {
+	now := time.Now()
+	session.RevokedAt = &now
+	session.RevokedReason = reason
+	return tx.Save(session).Error
+}
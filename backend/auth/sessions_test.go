@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCheckRefresh(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Minute)
+	active := models.Session{ExpiresAt: now.Add(time.Hour)}
+
+	tests := []struct {
+		name    string
+		token   models.RefreshToken
+		session models.Session
+		wantErr error
+	}{
+		{
+			name:    "unused token",
+			token:   models.RefreshToken{ExpiresAt: now.Add(time.Hour)},
+			session: active,
+		},
+		{
+			name:    "reused token",
+			token:   models.RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier},
+			session: active,
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name:    "revoked session wins over reuse",
+			token:   models.RefreshToken{ExpiresAt: now.Add(time.Hour), UsedAt: &earlier},
+			session: models.Session{ExpiresAt: now.Add(time.Hour), RevokedAt: &earlier},
+			wantErr: ErrSessionRevoked,
+		},
+		{
+			name:    "expired token",
+			token:   models.RefreshToken{ExpiresAt: earlier},
+			session: active,
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name:    "expired session",
+			token:   models.RefreshToken{ExpiresAt: now.Add(time.Hour)},
+			session: models.Session{ExpiresAt: earlier},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRefresh(tt.token, tt.session, now); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkRefresh() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRefreshReuseRevokesSession runs against a real database when
+// TEST_DATABASE_URL is set, e.g. a throwaway Postgres in CI.
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", dsn)
+	config.InitDB()
+	loadTestKeys(t, nil)
+
+	user := models.User{Email: "refresh-" + time.Now().Format("150405.000000") + "@example.com", Name: "Refresh"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		config.DB.Where("session_id IN (?)", config.DB.Model(&models.Session{}).Select("id").Where("user_id = ?", user.ID)).
+			Delete(&models.RefreshToken{})
+		config.DB.Where("user_id = ?", user.ID).Delete(&models.Session{})
+		config.DB.Delete(&user)
+	})
+
+	first, err := StartSession(user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Refresh(first.RefreshToken, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if second.SessionID != first.SessionID {
+		t.Fatal("refresh started a new session")
+	}
+
+	if _, err := Refresh(first.RefreshToken, "test", "127.0.0.1"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if SessionActive(first.SessionID) {
+		t.Fatal("session still active after refresh token reuse")
+	}
+	if _, err := Refresh(second.RefreshToken, "test", "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("refresh after revocation error = %v, want %v", err, ErrSessionRevoked)
+	}
+}
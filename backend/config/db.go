@@ -1,9 +1,12 @@
 package config
 
 import (
+	"context"
+	"deltra-backend/logging"
 	"deltra-backend/models"
-	"log"
+	"log/slog"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"gorm.io/driver/postgres"
@@ -12,26 +15,59 @@ import (
 
 var DB *gorm.DB
 
+// schema lists the models migrated at startup, in migration order.
+var schema = []any{
+	&models.User{},
+	&models.Stock{},
+	&models.Portfolio{},
+	&models.CoveredCall{},
+	&models.CorporateAction{},
+	&models.CorporateActionAdjustment{},
+	&models.Dividend{},
+	&models.FeeSchedule{},
+	&models.CashTransaction{},
+	&models.PortfolioSnapshot{},
+	&models.StockSnapshot{},
+	&models.Session{},
+	&models.RefreshToken{},
+	&models.APIKey{},
+	&models.Identity{},
+	&models.PortfolioMember{},
+	&models.ShareLink{},
+	&models.AuditEvent{},
+	&models.CalendarFeed{},
+	&models.AlertRule{},
+	&models.AlertEvent{},
+	&models.Notification{},
+	&models.NotificationDelivery{},
+	&models.PushDevice{},
+	&models.NotificationWebhook{},
+	&models.WebhookEndpoint{},
+	&models.WebhookDelivery{},
+}
+
+var migrated atomic.Bool
+
 func InitDB() {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		log.Fatal("DATABASE_URL environment variable is not set")
+		logging.Fatal("DATABASE_URL environment variable is not set")
 	}
 
-	log.Println("connecting")
+	slog.Info("Connecting to database")
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.GormLogger{}})
 
 	if err != nil {
-		log.Fatalf("failed to connect %v", err)
+		logging.Fatal("Failed to connect to database", "error", err)
 	}
 
-	log.Println("connected")
+	slog.Info("Connected to database")
 
 	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatalf("failed %v", err)
+		logging.Fatal("Failed to reach database", "error", err)
 	}
 
 	sqlDB.SetMaxOpenConns(10)
@@ -40,11 +76,177 @@ func InitDB() {
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
 
 	if err := sqlDB.Ping(); err != nil {
-		log.Fatalf("failed %v", err)
+		logging.Fatal("Failed to reach database", "error", err)
+	}
+	slog.Info("Running database migrations")
+	deliveryTracked := DB.Migrator().HasColumn("covered_calls", "shares_delivered")
+	reinvestmentTracked := DB.Migrator().HasColumn("dividends", "reinvested_at")
+	identitiesTracked := DB.Migrator().HasTable(&models.Identity{})
+	causesTracked := DB.Migrator().HasColumn("audit_events", "cause_type")
+	if err := DB.AutoMigrate(schema...); err != nil {
+		logging.Fatal("Failed to migrate database", "error", err)
+	}
+
+	// Dividends recorded before reinvested_at existed added their shares when
+	// they were created.
+	if !reinvestmentTracked {
+		if err := DB.Exec(`UPDATE dividends SET reinvested_at = created_at
+			WHERE reinvested_shares > 0 AND reinvested_at IS NULL`).Error; err != nil {
+			logging.Fatal("Failed to backfill dividend reinvestments", "error", err)
+		}
+	}
+
+	if !deliveryTracked {
+		if err := backfillDeliveredShares(DB); err != nil {
+			logging.Fatal("Failed to backfill assigned shares", "error", err)
+		}
+	}
+
+	if !causesTracked {
+		if err := backfillAuditCauses(DB); err != nil {
+			logging.Fatal("Failed to backfill audit event causes", "error", err)
+		}
+	}
+
+	// Users created before identities had their own table kept a single
+	// provider on the users row; copy those across once, so identities
+	// unlinked later don't come back.
+	if !identitiesTracked && DB.Migrator().HasColumn("users", "provider_id") {
+		if err := DB.Exec(`INSERT INTO identities (user_id, provider, subject, email, last_used_at, created_at)
+			SELECT id, provider, provider_id, email, updated_at, created_at FROM users
+			WHERE provider_id IS NOT NULL AND provider_id <> ''
+			ON CONFLICT (provider, subject) DO NOTHING`).Error; err != nil {
+			logging.Fatal("Failed to backfill identities", "error", err)
+		}
+	}
+	// Every portfolio needs an owner membership; portfolios created before
+	// sharing existed only had user_id.
+	if err := DB.Exec(`INSERT INTO portfolio_members (portfolio_id, user_id, email, role, status, accepted_at, created_at, updated_at)
+		SELECT p.id, p.user_id, u.email, 'owner', 'accepted', p.created_at, p.created_at, NOW()
+		FROM portfolios p JOIN users u ON u.id = p.user_id
+		WHERE NOT EXISTS (SELECT 1 FROM portfolio_members m WHERE m.portfolio_id = p.id AND m.role = 'owner')
+		ON CONFLICT (portfolio_id, email) DO NOTHING`).Error; err != nil {
+		logging.Fatal("Failed to backfill portfolio owners", "error", err)
+	}
+
+	migrated.Store(true)
+	slog.Info("Database migration completed successfully")
+}
+
+// backfillDeliveredShares marks assignments recorded before shares_delivered
+// existed as delivered. They credited the proceeds but left the shares in
+// the position, so those come out now, unless the stock was edited after
+// the assignment was recorded: that edit was most likely the user taking
+// them out by hand, so those positions are only logged for review.
+func backfillDeliveredShares(db *gorm.DB) error {
+	type assignment struct {
+		CallID         string
+		StockID        string
+		Symbol         string
+		SharesCovered  int
+		CallUpdatedAt  time.Time
+		StockUpdatedAt time.Time
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var assignments []assignment
+		if err := tx.Raw(`SELECT c.id AS call_id, c.stock_id, s.symbol, c.shares_covered,
+				c.updated_at AS call_updated_at, s.updated_at AS stock_updated_at
+			FROM covered_calls c JOIN stocks s ON s.id = c.stock_id
+			WHERE c.status = 'assigned' AND NOT c.shares_delivered`).
+			Scan(&assignments).Error; err != nil {
+			return err
+		}
+
+		delivered := map[string]int{}
+		for _, a := range assignments {
+			if a.StockUpdatedAt.After(a.CallUpdatedAt) {
+				slog.Warn("Stock edited after assignment, left its shares alone; review the position",
+					"stock_id", a.StockID, "symbol", a.Symbol, "call_id", a.CallID, "shares_covered", a.SharesCovered)
+				continue
+			}
+			delivered[a.StockID] += a.SharesCovered
+		}
+
+		for stockID, shares := range delivered {
+			if err := tx.Exec(`UPDATE stocks SET
+					purchase_fees = CASE WHEN shares > 0 THEN purchase_fees * GREATEST(shares - ?, 0) / shares ELSE purchase_fees END,
+					shares = GREATEST(shares - ?, 0)
+				WHERE id = ?`, shares, shares, stockID).Error; err != nil {
+				return err
+			}
+			slog.Info("Removed assigned shares from position", "stock_id", stockID, "shares", shares)
+		}
+
+		return tx.Exec(`UPDATE covered_calls SET shares_delivered = true
+			WHERE status = 'assigned' AND NOT shares_delivered`).Error
+	})
+}
+
+// backfillAuditCauses links audit events recorded before cause_type existed
+// to what caused them. Shares delivered on assignment were audited on the
+// stock in the same request as the call, and a corporate action's
+// adjustments were recorded alongside their audit events.
+func backfillAuditCauses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE audit_events s SET cause_type = 'assignment', cause_id = c.entity_id
+			FROM audit_events c
+			WHERE s.entity_type = 'stock' AND s.action = 'update' AND s.cause_id IS NULL
+				AND c.entity_type = 'covered_call' AND c.changes -> 'shares_delivered' IS NOT NULL
+				AND c.request_id <> '' AND c.request_id = s.request_id
+				AND c.after ->> 'stock_id' = s.entity_id::text`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE audit_events e SET cause_type = 'corporate_action', cause_id = a.corporate_action_id
+			FROM corporate_action_adjustments a
+			WHERE e.cause_id IS NULL AND e.action IN ('create', 'update')
+				AND e.entity_type = a.entity_type AND e.entity_id = a.entity_id
+				AND e.created_at BETWEEN a.created_at - INTERVAL '5 seconds' AND a.created_at + INTERVAL '5 seconds'`).Error
+	})
+}
+
+// CheckReady reports why the database can't serve requests yet: it is
+// unreachable, or migrations have not finished or left tables missing. The
+// reasons are generic since /readyz is public; details go to the log.
+func CheckReady(ctx context.Context) map[string]string {
+	problems := map[string]string{}
+
+	sqlDB, err := DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Readiness check failed", "check", "database", "error", err)
+		problems["database"] = "unreachable"
+		return problems
+	}
+
+	if !migrated.Load() {
+		problems["migrations"] = "migrations have not completed"
+		return problems
+	}
+	tables, err := DB.WithContext(ctx).Migrator().GetTables()
+	if err != nil {
+		slog.WarnContext(ctx, "Readiness check failed", "check", "migrations", "error", err)
+		problems["migrations"] = "schema could not be checked"
+		return problems
+	}
+	existing := map[string]bool{}
+	for _, table := range tables {
+		existing[table] = true
 	}
-	log.Println("Running database migrations...")
-	if err := DB.AutoMigrate(&models.User{}, &models.Stock{}, &models.Portfolio{}, &models.CoveredCall{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+	for _, model := range schema {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			slog.WarnContext(ctx, "Readiness check failed", "check", "migrations", "error", err)
+			problems["migrations"] = "schema could not be checked"
+			break
+		}
+		if !existing[stmt.Schema.Table] {
+			slog.WarnContext(ctx, "Readiness check failed", "check", "migrations", "missing_table", stmt.Schema.Table)
+			problems["migrations"] = "schema is incomplete"
+			break
+		}
 	}
-	log.Println("Database migration completed successfully")
+	return problems
 }
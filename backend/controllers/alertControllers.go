@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateAlertRuleRequest struct {
+	Type        string   `json:"type" binding:"required"`
+	PortfolioID *string  `json:"portfolio_id,omitempty"`
+	StockID     *string  `json:"stock_id,omitempty"`
+	Threshold   *float64 `json:"threshold,omitempty"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+}
+
+type UpdateAlertRuleRequest struct {
+	Threshold *float64 `json:"threshold,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+}
+
+var alertEventList = query.Spec{
+	Sorts:       map[string]string{"triggered_at": "triggered_at"},
+	DefaultSort: "-triggered_at",
+	Filters: []query.Filter{
+		query.Equals("rule_id", "rule_id"),
+		query.Equals("type", "type"),
+		query.Equals("portfolio_id", "portfolio_id"),
+		query.Equals("stock_id", "stock_id"),
+		query.Custom("open", func(db *gorm.DB, values []string) *gorm.DB {
+			if values[0] == "true" {
+				return db.Where("cleared_at IS NULL")
+			}
+			return db.Where("cleared_at IS NOT NULL")
+		}),
+		query.DateRange("triggered", "triggered_at"),
+	},
+}
+
+// validateAlertThreshold rejects zero along with out-of-range values: a
+// stored zero means the rule uses the default, so it can't be asked for.
+func validateAlertThreshold(alertType string, threshold float64) string {
+	switch alertType {
+	case models.AlertProfitCaptured:
+		if threshold <= 0 || threshold > 100 {
+			return "threshold must be a percentage above 0 and at most 100"
+		}
+	case models.AlertExpirationNear:
+		if threshold <= 0 {
+			return "threshold must be a positive number of days"
+		}
+	}
+	return ""
+}
+
+func GetAlertRules(c *gin.Context) {
+	userID := c.Param("id")
+
+	var rules []models.AlertRule
+	if err := dbFor(c).Where("user_id = ?", userID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func CreateAlertRule(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.ValidAlertType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+	if req.Threshold != nil {
+		if msg := validateAlertThreshold(req.Type, *req.Threshold); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	// Any member can watch a portfolio, viewers included.
+	if req.PortfolioID != nil && portfolioRole(userID, *req.PortfolioID) == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+	if req.StockID != nil {
+		var stock models.Stock
+		if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", *req.StockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
+			return
+		}
+	}
+
+	rule := models.AlertRule{
+		UserID:      userID,
+		PortfolioID: req.PortfolioID,
+		StockID:     req.StockID,
+		Type:        req.Type,
+		Enabled:     true,
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	if err := dbFor(c).Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func UpdateAlertRule(c *gin.Context) {
+	userID := c.Param("id")
+	ruleID := c.Param("ruleId")
+
+	var req UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rule models.AlertRule
+	if err := dbFor(c).Where("id = ? AND user_id = ?", ruleID, userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	if req.Threshold != nil {
+		if msg := validateAlertThreshold(rule.Type, *req.Threshold); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		rule.Threshold = *req.Threshold
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	if err := dbFor(c).Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteAlertRule(c *gin.Context) {
+	userID := c.Param("id")
+	ruleID := c.Param("ruleId")
+
+	result := dbFor(c).Where("id = ? AND user_id = ?", ruleID, userID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
+
+func GetAlertEvents(c *gin.Context) {
+	userID := c.Param("id")
+
+	var events []models.AlertEvent
+	if !findList(c, alertEventList, dbFor(c).Where("user_id = ?", userID), &events, "Failed to fetch alerts") {
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
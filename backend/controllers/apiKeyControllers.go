@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"deltra-backend/auth"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scope     string     `json:"scope"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func GetAPIKeys(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var keys []models.APIKey
+	if err := dbFor(c).Where("user_id = ? AND revoked_at IS NULL", principal.UserID).
+		Order("created_at DESC").
+		Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func CreateAPIKey(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Scope == "" {
+		req.Scope = models.APIKeyScopeRead
+	}
+	if req.Scope != models.APIKeyScopeRead && req.Scope != models.APIKeyScopeReadWrite {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope. Must be 'read' or 'read_write'"})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	key, raw, err := auth.CreateAPIKey(principal.UserID, req.Name, req.Scope, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: key, Key: raw})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+	keyID := c.Param("keyId")
+
+	err := auth.RevokeAPIKey(principal.UserID, keyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"deltra-backend/audit"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAuditConflict      = errors.New("entity changed since this event")
+	errAuditNotRevertible = errors.New("event cannot be reverted")
+	errAuditMovedCash     = errors.New("event moved cash or shares")
+	errAuditCorporate     = errors.New("event is part of a corporate action")
+)
+
+// auditActor is the authenticated principal behind a request.
+func auditActor(c *gin.Context) audit.Actor {
+	principal, _ := middleware.AuthPrincipal(c)
+	return audit.Actor{
+		UserID:    principal.UserID,
+		APIKeyID:  principal.APIKeyID,
+		RequestID: middleware.GetRequestID(c),
+	}
+}
+
+// recordAudit appends an audit event for a change made in tx. Pass the state
+// captured before the change, or nil for creates.
+func recordAudit(tx *gorm.DB, c *gin.Context, action string, entity any, before map[string]any) error {
+	change, err := audit.Record(tx, auditActor(c), action, entity, before)
+	if err != nil {
+		return err
+	}
+	middleware.QueueChange(c, change)
+	return nil
+}
+
+// recordCausedAudit records a change made as a side effect of another, linked
+// to what caused it so the two are never reverted apart.
+func recordCausedAudit(tx *gorm.DB, c *gin.Context, causeType, causeID, action string, entity any, before map[string]any) error {
+	var after map[string]any
+	if action != models.AuditDelete {
+		after = audit.State(entity)
+	}
+	event := audit.NewEvent(auditActor(c), action, entity, before, after)
+	event.CauseType = causeType
+	event.CauseID = &causeID
+	return createAuditEvent(tx, c, event)
+}
+
+// createAuditEvent stores an audit event and queues its portfolio event for
+// broadcast once the request succeeds.
+func createAuditEvent(tx *gorm.DB, c *gin.Context, event models.AuditEvent) error {
+	change, err := audit.Create(tx, event)
+	if err != nil {
+		return err
+	}
+	middleware.QueueChange(c, change)
+	return nil
+}
+
+// revertMovesCash reports whether undoing event would leave the cash ledger
+// out of step: the change posted a cash transaction (a purchase, premium,
+// buyback or assignment) or delivered shares on assignment.
+func revertMovesCash(tx *gorm.DB, event models.AuditEvent) (bool, error) {
+	if event.CauseType == models.AuditCauseAssignment {
+		return true, nil
+	}
+
+	var changed map[string]audit.FieldChange
+	json.Unmarshal(event.Changes, &changed)
+
+	switch {
+	case event.EntityType == "covered_call" && event.Action == models.AuditUpdate:
+		if status, ok := changed["status"]; ok {
+			to, _ := status.To.(string)
+			from, _ := status.From.(string)
+			if callCashFlow(to, from) != "" {
+				return true, nil
+			}
+		}
+		_, delivered := changed["shares_delivered"]
+		return delivered, nil
+
+	case event.EntityType == "stock" && event.Action == models.AuditCreate:
+		var count int64
+		err := tx.Model(&models.CashTransaction{}).
+			Where("stock_id = ? AND type = ?", event.EntityID, "stock_purchase").
+			Count(&count).Error
+		return count > 0, err
+	}
+	return false, nil
+}
+
+// saveAudited saves an update to entity along with its audit event.
+func saveAudited(c *gin.Context, entity any, before map[string]any) error {
+	return dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(entity).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditUpdate, entity, before)
+	})
+}
+
+// deleteAudited deletes entity along with recording its final state.
+func deleteAudited(c *gin.Context, entity any) error {
+	before := audit.State(entity)
+	return dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := softDelete(tx, entity); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditDelete, entity, before)
+	})
+}
+
+var auditEventList = query.Spec{
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-created_at",
+	Filters: []query.Filter{
+		query.Equals("entity_type", "entity_type"),
+		query.Equals("entity_id", "entity_id"),
+		query.Equals("portfolio_id", "portfolio_id"),
+		query.Equals("action", "action"),
+		query.DateRange("created", "created_at"),
+	},
+}
+
+func GetAuditEvents(c *gin.Context) {
+	userID := c.Param("id")
+
+	// Events for deleted portfolios are still visible to their owner.
+	visible := dbFor(c).Where("portfolio_id IN (?) OR user_id = ?", memberPortfolioIDs(userID), userID)
+
+	var events []models.AuditEvent
+	if !findList(c, auditEventList, visible, &events, "Failed to fetch audit events") {
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// RevertAuditEvent undoes a single change: creates are deleted, deletes are
+// restored, and updates have the changed fields set back. Updates are only
+// reverted while those fields still hold the values the event wrote. Changes
+// that moved cash are refused, since undoing them would desync the ledger, as
+// are a corporate action's adjustments, which only make sense together.
+func RevertAuditEvent(c *gin.Context) {
+	userID := c.Param("id")
+	eventID := c.Param("eventId")
+
+	var event models.AuditEvent
+	if err := dbFor(c).Where("id = ? AND (portfolio_id IN (?) OR user_id = ?)", eventID, memberPortfolioIDs(userID), userID).
+		First(&event).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audit event not found"})
+		return
+	}
+
+	if event.EntityType == "portfolio" {
+		if event.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this portfolio"})
+			return
+		}
+	} else if !requirePortfolioRole(c, userID, event.PortfolioID, editorRoles...) {
+		return
+	}
+
+	var revertEvent models.AuditEvent
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		entity := audit.NewEntity(event.EntityType)
+		if entity == nil {
+			return errAuditNotRevertible
+		}
+
+		if event.CauseType == models.AuditCauseCorporateAction {
+			return errAuditCorporate
+		}
+		if movesCash, err := revertMovesCash(tx, event); err != nil {
+			return err
+		} else if movesCash {
+			return errAuditMovedCash
+		}
+
+		var before, after map[string]any
+		json.Unmarshal(event.Before, &before)
+		json.Unmarshal(event.After, &after)
+
+		// Deleted rows sit in the trash until purged, so look there too.
+		var current map[string]any
+		exists, trashed := true, false
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", event.EntityID).
+			First(entity).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			exists = false
+		} else if err != nil {
+			return err
+		} else {
+			trashed = isTrashed(entity)
+			exists = !trashed
+			current = audit.State(entity)
+		}
+
+		switch event.Action {
+		case models.AuditCreate:
+			if !exists {
+				return errAuditConflict
+			}
+			if err := softDelete(tx, entity); err != nil {
+				return err
+			}
+			revertEvent = audit.NewEvent(auditActor(c), models.AuditRevert, entity, current, nil)
+
+		case models.AuditDelete:
+			if exists {
+				return errAuditConflict
+			}
+			if trashed {
+				if err := restoreDeleted(tx, entity); err != nil {
+					return err
+				}
+				if err := tx.Where("id = ?", event.EntityID).First(entity).Error; err != nil {
+					return err
+				}
+				revertEvent = audit.NewEvent(auditActor(c), models.AuditRevert, entity, current, audit.State(entity))
+				break
+			}
+			if err := json.Unmarshal(event.Before, entity); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Create(entity).Error; err != nil {
+				return err
+			}
+			if portfolio, ok := entity.(*models.Portfolio); ok {
+				var owner models.User
+				if err := tx.Where("id = ?", portfolio.UserID).First(&owner).Error; err != nil {
+					return err
+				}
+				if err := addPortfolioOwner(tx, *portfolio, owner); err != nil {
+					return err
+				}
+			}
+			revertEvent = audit.NewEvent(auditActor(c), models.AuditRevert, entity, nil, audit.State(entity))
+
+		case models.AuditUpdate:
+			if !exists {
+				return errAuditConflict
+			}
+
+			var changed map[string]audit.FieldChange
+			json.Unmarshal(event.Changes, &changed)
+
+			columns := make([]string, 0, len(changed))
+			for column := range changed {
+				if !reflect.DeepEqual(current[column], after[column]) {
+					return errAuditConflict
+				}
+				columns = append(columns, column)
+			}
+			if len(columns) == 0 {
+				return errAuditNotRevertible
+			}
+
+			restored := audit.NewEntity(event.EntityType)
+			if err := json.Unmarshal(event.Before, restored); err != nil {
+				return err
+			}
+			if err := tx.Model(entity).Select(columns).Updates(restored).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("id = ?", event.EntityID).First(entity).Error; err != nil {
+				return err
+			}
+			revertEvent = audit.NewEvent(auditActor(c), models.AuditRevert, entity, current, audit.State(entity))
+
+		default:
+			return errAuditNotRevertible
+		}
+
+		revertEvent.RevertedEvent = &event.ID
+		return createAuditEvent(tx, c, revertEvent)
+	})
+
+	if errors.Is(err, errAuditConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This change can't be reverted because the record has changed since"})
+		return
+	}
+	if errors.Is(err, errParentDeleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Restore the portfolio or stock this belongs to first"})
+		return
+	}
+	if errors.Is(err, errAuditMovedCash) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This change moved cash or shares; record an offsetting transaction instead of reverting it"})
+		return
+	}
+	if errors.Is(err, errAuditCorporate) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This change was made by a corporate action and can't be reverted on its own"})
+		return
+	}
+	if errors.Is(err, errAuditNotRevertible) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This event cannot be reverted"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revert change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revertEvent)
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"deltra-backend/auth"
+	"deltra-backend/config"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/performance"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Calls within this fraction of the strike count as at the money.
+const atTheMoneyBand = 0.01
+
+type CalendarResponse struct {
+	From         time.Time        `json:"from"`
+	To           time.Time        `json:"to"`
+	Group        string           `json:"group"`
+	MarketPriced bool             `json:"market_priced"`
+	Periods      []CalendarPeriod `json:"periods"`
+}
+
+// CalendarPeriod is one week or month of expirations with totals.
+type CalendarPeriod struct {
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	Contracts     int           `json:"contracts"`
+	SharesCovered int           `json:"shares_covered"`
+	NetPremium    float64       `json:"net_premium"`
+	PremiumAtRisk float64       `json:"premium_at_risk"`
+	Expirations   []CalendarDay `json:"expirations"`
+}
+
+type CalendarDay struct {
+	Date  time.Time      `json:"date"`
+	Calls []CalendarCall `json:"calls"`
+}
+
+// CalendarCall describes an open call against the current price. Price
+// fields are left out when no quote is available. PremiumAtRisk is the part
+// of the net premium that buying the call back at intrinsic value would
+// give up.
+type CalendarCall struct {
+	ID             string    `json:"id"`
+	PortfolioID    string    `json:"portfolio_id"`
+	PortfolioName  string    `json:"portfolio_name"`
+	StockID        string    `json:"stock_id"`
+	Symbol         string    `json:"symbol"`
+	Status         string    `json:"status"`
+	ExpirationDate time.Time `json:"expiration_date"`
+	Contracts      int       `json:"contracts"`
+	SharesCovered  int       `json:"shares_covered"`
+	StrikePrice    float64   `json:"strike_price"`
+	NetPremium     float64   `json:"net_premium"`
+	CurrentPrice   *float64  `json:"current_price,omitempty"`
+	Moneyness      string    `json:"moneyness,omitempty"`
+	MoneynessPct   *float64  `json:"moneyness_pct,omitempty"`
+	IntrinsicValue *float64  `json:"intrinsic_value,omitempty"`
+	PremiumAtRisk  *float64  `json:"premium_at_risk,omitempty"`
+}
+
+type CreateCalendarFeedResponse struct {
+	models.CalendarFeed
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// openCalls returns the user's pending and active calls expiring in
+// [from, to), soonest first.
+func openCalls(userID string, from, to time.Time) ([]models.CoveredCall, error) {
+	var calls []models.CoveredCall
+	err := config.DB.Where("portfolio_id IN (?) AND status IN ?", memberPortfolioIDs(userID), []string{"pending", "active"}).
+		Where("expiration_date >= ? AND expiration_date < ?", from, to).
+		Preload("Stock").
+		Preload("Portfolio").
+		Order("expiration_date ASC, strike_price ASC").
+		Find(&calls).Error
+	return calls, err
+}
+
+func GetCalendar(c *gin.Context) {
+	userID := c.Param("id")
+
+	today := performance.Day(time.Now())
+	from, to := today, today.AddDate(0, 6, 0)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return
+	}
+
+	group := c.DefaultQuery("group", "week")
+	if group != "week" && group != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group must be week or month"})
+		return
+	}
+
+	calls, err := openCalls(userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch covered calls"})
+		return
+	}
+
+	entries, complete := calendarCalls(c.Request.Context(), calls)
+
+	response := CalendarResponse{
+		From:         from,
+		To:           to,
+		Group:        group,
+		MarketPriced: complete,
+		Periods:      groupCalendar(entries, group),
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func calendarCalls(ctx context.Context, calls []models.CoveredCall) ([]CalendarCall, bool) {
+	complete := marketdata.Configured()
+	quotes := make(map[string]*float64)
+
+	entries := make([]CalendarCall, 0, len(calls))
+	for _, call := range calls {
+		symbol := strings.ToUpper(call.Stock.Symbol)
+		entry := CalendarCall{
+			ID:             call.ID,
+			PortfolioID:    call.PortfolioID,
+			PortfolioName:  call.Portfolio.Name,
+			StockID:        call.StockID,
+			Symbol:         symbol,
+			Status:         call.Status,
+			ExpirationDate: call.ExpirationDate,
+			Contracts:      call.Contracts,
+			SharesCovered:  call.SharesCovered,
+			StrikePrice:    call.StrikePrice,
+			NetPremium:     call.NetPremium,
+		}
+
+		price, seen := quotes[symbol]
+		if !seen && complete {
+			if quote, err := marketdata.GetQuote(ctx, symbol); err == nil {
+				price = &quote.Price
+			}
+			quotes[symbol] = price
+		}
+		if price == nil {
+			complete = false
+		} else if call.StrikePrice > 0 {
+			entry.CurrentPrice = price
+			moneyness := *price/call.StrikePrice - 1
+			entry.MoneynessPct = &moneyness
+			switch {
+			case math.Abs(moneyness) <= atTheMoneyBand:
+				entry.Moneyness = "atm"
+			case moneyness > 0:
+				entry.Moneyness = "itm"
+			default:
+				entry.Moneyness = "otm"
+			}
+
+			intrinsic := math.Max(0, *price-call.StrikePrice) * float64(call.SharesCovered)
+			atRisk := math.Min(intrinsic, math.Max(0, call.NetPremium))
+			entry.IntrinsicValue = &intrinsic
+			entry.PremiumAtRisk = &atRisk
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, complete
+}
+
+// groupCalendar buckets calls, already sorted by expiration, into weeks
+// starting Monday or calendar months.
+func groupCalendar(calls []CalendarCall, group string) []CalendarPeriod {
+	periods := []CalendarPeriod{}
+
+	for _, call := range calls {
+		day := performance.Day(call.ExpirationDate)
+
+		var start, end time.Time
+		if group == "month" {
+			start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+			end = start.AddDate(0, 1, -1)
+		} else {
+			start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+			end = start.AddDate(0, 0, 6)
+		}
+
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(start) {
+			periods = append(periods, CalendarPeriod{Start: start, End: end})
+		}
+		period := &periods[len(periods)-1]
+
+		if n := len(period.Expirations); n == 0 || !period.Expirations[n-1].Date.Equal(day) {
+			period.Expirations = append(period.Expirations, CalendarDay{Date: day})
+		}
+		expiration := &period.Expirations[len(period.Expirations)-1]
+		expiration.Calls = append(expiration.Calls, call)
+
+		period.Contracts += call.Contracts
+		period.SharesCovered += call.SharesCovered
+		period.NetPremium += call.NetPremium
+		if call.PremiumAtRisk != nil {
+			period.PremiumAtRisk += *call.PremiumAtRisk
+		}
+	}
+
+	return periods
+}
+
+// GetCalendarICS serves the signed-in user's expirations as iCalendar.
+func GetCalendarICS(c *gin.Context) {
+	writeCalendarICS(c, c.Param("id"))
+}
+
+// GetCalendarFeed serves a calendar subscription without authentication.
+// Unknown tokens get a 404.
+func GetCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var feed models.CalendarFeed
+	if err := dbFor(c).Where("token_hash = ?", auth.HashToken(token)).First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+
+	now := time.Now()
+	dbFor(c).Model(&feed).Update("last_fetched_at", now)
+
+	writeCalendarICS(c, feed.UserID)
+}
+
+func writeCalendarICS(c *gin.Context, userID string) {
+	// Keep a month of history so recent expirations don't vanish from the
+	// calendar the day after.
+	today := performance.Day(time.Now())
+	calls, err := openCalls(userID, today.AddDate(0, -1, 0), today.AddDate(2, 0, 0))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch covered calls"})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="deltra-expirations.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(expirationsICS(calls, time.Now())))
+}
+
+// expirationsICS renders one all-day event per call (RFC 5545).
+func expirationsICS(calls []models.CoveredCall, now time.Time) string {
+	var b strings.Builder
+	line := func(content string) {
+		b.WriteString(foldICSLine(content))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Deltra//Covered call expirations//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Covered call expirations")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, call := range calls {
+		day := performance.Day(call.ExpirationDate)
+		symbol := strings.ToUpper(call.Stock.Symbol)
+
+		line("BEGIN:VEVENT")
+		line("UID:" + call.ID + "@deltra")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICSText(fmt.Sprintf("%s %dx $%.2f call expires", symbol, call.Contracts, call.StrikePrice)))
+		line("DESCRIPTION:" + escapeICSText(fmt.Sprintf(
+			"Portfolio: %s\nStatus: %s\nShares covered: %d\nStrike: $%.2f\nNet premium: $%.2f",
+			call.Portfolio.Name, call.Status, call.SharesCovered, call.StrikePrice, call.NetPremium,
+		)))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func escapeICSText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// foldICSLine splits content lines longer than 75 octets, never inside a
+// UTF-8 sequence.
+func foldICSLine(content string) string {
+	const limit = 75
+
+	var b strings.Builder
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+func CreateCalendarFeed(c *gin.Context) {
+	userID := c.Param("id")
+
+	raw, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	// One feed per user: creating a new one retires the old URL.
+	feed := models.CalendarFeed{UserID: userID, TokenHash: hash}
+	if err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&feed).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar feed"})
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	c.JSON(http.StatusCreated, CreateCalendarFeedResponse{
+		CalendarFeed: feed,
+		Token:        raw,
+		URL:          fmt.Sprintf("%s://%s/v1/calendar/%s.ics", scheme, c.Request.Host, raw),
+	})
+}
+
+func DeleteCalendarFeed(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := dbFor(c).Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete calendar feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed deleted successfully"})
+}
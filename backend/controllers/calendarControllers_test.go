@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"deltra-backend/models"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// unfoldICS reverses line folding as a calendar client would (RFC 5545 3.1).
+func unfoldICS(folded string) string {
+	return strings.ReplaceAll(folded, "\r\n ", "")
+}
+
+func TestFoldICSLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   int
+	}{
+		{name: "short line", content: "SUMMARY:AAPL call expires", lines: 1},
+		{name: "exactly 75 octets", content: "DESCRIPTION:" + strings.Repeat("a", 63), lines: 1},
+		{name: "76 octets", content: "DESCRIPTION:" + strings.Repeat("a", 64), lines: 2},
+		{name: "long line", content: "DESCRIPTION:" + strings.Repeat("abcdefghij", 20), lines: 3},
+		{name: "multi-byte runes", content: "DESCRIPTION:" + strings.Repeat("€", 60), lines: 3},
+		{name: "four-byte runes", content: "SUMMARY:" + strings.Repeat("📈", 40), lines: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := foldICSLine(tt.content)
+
+			if got := unfoldICS(folded); got != tt.content {
+				t.Fatalf("unfolded = %q, want %q", got, tt.content)
+			}
+
+			lines := strings.Split(folded, "\r\n")
+			if len(lines) != tt.lines {
+				t.Errorf("folded into %d lines, want %d", len(lines), tt.lines)
+			}
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+			}
+		})
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	got := escapeICSText("Income; covered, calls\\puts\nline two")
+	want := `Income\; covered\, calls\\puts\nline two`
+	if got != want {
+		t.Errorf("escapeICSText() = %q, want %q", got, want)
+	}
+}
+
+func TestExpirationsICS(t *testing.T) {
+	calls := []models.CoveredCall{{
+		ID:             "call-1",
+		Contracts:      2,
+		SharesCovered:  200,
+		StrikePrice:    150,
+		NetPremium:     310.5,
+		Status:         "active",
+		ExpirationDate: time.Date(2024, 3, 15, 20, 0, 0, 0, time.UTC),
+		Stock:          models.Stock{Symbol: "aapl"},
+		Portfolio:      models.Portfolio{Name: "Income, long-term; " + strings.Repeat("very ", 20) + "descriptive"},
+	}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	ics := expirationsICS(calls, now)
+
+	if !strings.HasSuffix(ics, "END:VCALENDAR\r\n") {
+		t.Error("calendar does not end with END:VCALENDAR and CRLF")
+	}
+	if strings.Contains(strings.ReplaceAll(ics, "\r\n", ""), "\n") {
+		t.Error("calendar contains a bare LF")
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets: %q", i, len(line), line)
+		}
+	}
+
+	unfolded := unfoldICS(ics)
+	for _, want := range []string{
+		"UID:call-1@deltra\r\n",
+		"DTSTAMP:20240301T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20240315\r\n",
+		"DTEND;VALUE=DATE:20240316\r\n",
+		"SUMMARY:AAPL 2x $150.00 call expires\r\n",
+		`DESCRIPTION:Portfolio: Income\, long-term\; very `,
+		`\nStatus: active\nShares covered: 200\nStrike: $150.00\nNet premium: $310.50` + "\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar is missing %q", want)
+		}
+	}
+}
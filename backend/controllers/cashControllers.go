@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"deltra-backend/audit"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CreateCashTransactionRequest struct {
+	Type        string     `json:"type" binding:"required"`
+	Amount      float64    `json:"amount" binding:"required"`
+	Description string     `json:"description"`
+	OccurredAt  *time.Time `json:"occurred_at,omitempty"`
+}
+
+type PortfolioSummary struct {
+	PortfolioID   string  `json:"portfolio_id"`
+	CashBalance   float64 `json:"cash_balance"`
+	StockValue    float64 `json:"stock_value"`
+	TotalValue    float64 `json:"total_value"`
+	NetDeposits   float64 `json:"net_deposits"`
+	TotalReturn   float64 `json:"total_return"`
+	ReturnPercent float64 `json:"return_percent"`
+	MarketPriced  bool    `json:"market_priced"`
+}
+
+var (
+	errInsufficientCash   = errors.New("insufficient cash")
+	errInsufficientShares = errors.New("insufficient shares")
+)
+
+func cashBalance(db *gorm.DB, portfolioID string) (float64, error) {
+	var balance float64
+	err := db.Model(&models.CashTransaction{}).
+		Where("portfolio_id = ?", portfolioID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// postCashTransaction records a cash movement, refusing debits that would
+// overdraw a portfolio with cash enforcement turned on.
+func postCashTransaction(tx *gorm.DB, txn *models.CashTransaction) error {
+	if txn.PortfolioID == "" || txn.Amount == 0 {
+		return nil
+	}
+
+	var portfolio models.Portfolio
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", txn.PortfolioID).
+		First(&portfolio).Error; err != nil {
+		return err
+	}
+
+	if portfolio.EnforceCash && txn.Amount < 0 {
+		balance, err := cashBalance(tx, portfolio.ID)
+		if err != nil {
+			return err
+		}
+		if balance+txn.Amount < 0 {
+			return errInsufficientCash
+		}
+	}
+
+	if txn.OccurredAt.IsZero() {
+		txn.OccurredAt = time.Now()
+	}
+
+	return tx.Create(txn).Error
+}
+
+func recordStockPurchase(tx *gorm.DB, stock models.Stock) error {
+	return postCashTransaction(tx, &models.CashTransaction{
+		PortfolioID: stock.PortfolioID,
+		UserID:      stock.UserID,
+		StockID:     &stock.ID,
+		Type:        "stock_purchase",
+		Amount:      -(stock.Shares*stock.Basis + stock.PurchaseFees),
+		Description: "Bought " + stock.Symbol,
+	})
+}
+
+// callCashFlow returns the type of ledger entry a call's status change posts,
+// or "" when the change moves no cash.
+func callCashFlow(status, previousStatus string) string {
+	switch {
+	case status == previousStatus:
+		return ""
+	case status == "active" && previousStatus == "pending":
+		return "premium"
+	case status == "bought_back":
+		return "buyback"
+	case status == "assigned":
+		return "assignment"
+	}
+	return ""
+}
+
+func recordCallCashFlow(tx *gorm.DB, call models.CoveredCall, previousStatus string) error {
+	txn := models.CashTransaction{
+		PortfolioID:   call.PortfolioID,
+		UserID:        call.UserID,
+		StockID:       &call.StockID,
+		CoveredCallID: &call.ID,
+		Type:          callCashFlow(call.Status, previousStatus),
+	}
+
+	switch txn.Type {
+	case "premium":
+		txn.Amount = call.TotalPremium - call.OpenFees
+	case "buyback":
+		txn.Amount = -call.BuybackFees - call.BuybackCost()
+		if call.BuybackDate != nil {
+			txn.OccurredAt = *call.BuybackDate
+		}
+	case "assignment":
+		price := call.StrikePrice
+		if call.AssignmentPrice != nil {
+			price = *call.AssignmentPrice
+		}
+		txn.Amount = price*float64(call.SharesCovered) - call.AssignmentFees
+		if call.AssignmentDate != nil {
+			txn.OccurredAt = *call.AssignmentDate
+		}
+	default:
+		return nil
+	}
+
+	return postCashTransaction(tx, &txn)
+}
+
+// deliverAssignedShares takes the covered shares out of the position when a
+// call is assigned, so they aren't counted alongside the assignment proceeds.
+// Per-share basis is unchanged; purchase fees shrink with the position.
+func deliverAssignedShares(tx *gorm.DB, c *gin.Context, call *models.CoveredCall) error {
+	if call.SharesDelivered {
+		return nil
+	}
+
+	var stock models.Stock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", call.StockID).
+		First(&stock).Error; err != nil {
+		return err
+	}
+
+	delivered := float64(call.SharesCovered)
+	if stock.Shares < delivered {
+		return errInsufficientShares
+	}
+
+	before := audit.State(&stock)
+	stock.PurchaseFees -= stock.PurchaseFees * delivered / stock.Shares
+	stock.Shares -= delivered
+	if err := tx.Save(&stock).Error; err != nil {
+		return err
+	}
+	call.SharesDelivered = true
+	return recordCausedAudit(tx, c, models.AuditCauseAssignment, call.ID, models.AuditUpdate, &stock, before)
+}
+
+var cashTransactionList = query.Spec{
+	Sorts: map[string]string{
+		"occurred_at": "occurred_at",
+		"amount":      "amount",
+		"created_at":  "created_at",
+	},
+	DefaultSort: "-occurred_at",
+	Filters: []query.Filter{
+		query.Equals("type", "type"),
+		query.Equals("stock_id", "stock_id"),
+		query.DateRange("occurred", "occurred_at"),
+	},
+}
+
+func GetCashTransactions(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var transactions []models.CashTransaction
+	if !findList(c, cashTransactionList, dbFor(c).Where("portfolio_id = ?", portfolio.ID),
+		&transactions, "Failed to fetch cash transactions") {
+		return
+	}
+
+	balance, err := cashBalance(dbFor(c), portfolio.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate cash balance"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance":      balance,
+		"transactions": transactions,
+	})
+}
+
+func CreateCashTransaction(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+	var req CreateCashTransactionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Type != "deposit" && req.Type != "withdrawal" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type. Must be 'deposit' or 'withdrawal'"})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be positive"})
+		return
+	}
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, portfolio.ID, editorRoles...) {
+		return
+	}
+
+	txn := models.CashTransaction{
+		PortfolioID: portfolio.ID,
+		UserID:      portfolio.UserID,
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Description: req.Description,
+	}
+	if req.Type == "withdrawal" {
+		txn.Amount = -req.Amount
+	}
+	if req.OccurredAt != nil {
+		txn.OccurredAt = *req.OccurredAt
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		return postCashTransaction(tx, &txn)
+	})
+	if errors.Is(err, errInsufficientCash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient cash"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cash transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, txn)
+}
+
+func GetPortfolioSummary(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).
+		Preload("Stocks").
+		First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var transactions []models.CashTransaction
+	if err := dbFor(c).Where("portfolio_id = ?", portfolio.ID).Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash transactions"})
+		return
+	}
+
+	summary := PortfolioSummary{
+		PortfolioID:  portfolio.ID,
+		MarketPriced: marketdata.Configured(),
+	}
+
+	for _, txn := range transactions {
+		summary.CashBalance += txn.Amount
+		if txn.IsExternal() {
+			summary.NetDeposits += txn.Amount
+		}
+	}
+
+	for _, stock := range portfolio.Stocks {
+		price := stock.Basis
+		if quote, err := marketdata.GetQuote(c.Request.Context(), stock.Symbol); err == nil {
+			price = quote.Price
+		} else {
+			summary.MarketPriced = false
+		}
+		summary.StockValue += price * stock.Shares
+	}
+
+	summary.TotalValue = summary.CashBalance + summary.StockValue
+	summary.TotalReturn = summary.TotalValue - summary.NetDeposits
+	if summary.NetDeposits > 0 {
+		summary.ReturnPercent = summary.TotalReturn / summary.NetDeposits * 100
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"deltra-backend/audit"
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CreateCorporateActionRequest struct {
+	StockID             string    `json:"stock_id" binding:"required"`
+	Type                string    `json:"type" binding:"required"`
+	EffectiveDate       time.Time `json:"effective_date" binding:"required"`
+	RatioFrom           float64   `json:"ratio_from"`
+	RatioTo             float64   `json:"ratio_to"`
+	NewSymbol           string    `json:"new_symbol"`
+	SpinOffSymbol       string    `json:"spin_off_symbol"`
+	SpinOffBasisPercent float64   `json:"spin_off_basis_percent"`
+	CashAmount          float64   `json:"cash_amount"`
+}
+
+var (
+	errCorporateActionApplied = errors.New("corporate action already applied")
+	errSpinOffOpenCalls       = errors.New("close or roll open calls before applying a spin-off")
+)
+
+func validateCorporateAction(req CreateCorporateActionRequest) string {
+	switch req.Type {
+	case "split", "reverse_split":
+		if req.RatioFrom <= 0 || req.RatioTo <= 0 {
+			return "ratio_from and ratio_to must be positive"
+		}
+		if req.Type == "split" && req.RatioTo <= req.RatioFrom {
+			return "A split must increase the share count"
+		}
+		if req.Type == "reverse_split" && req.RatioTo >= req.RatioFrom {
+			return "A reverse split must decrease the share count"
+		}
+	case "ticker_change":
+		if strings.TrimSpace(req.NewSymbol) == "" {
+			return "new_symbol is required for a ticker change"
+		}
+	case "spin_off":
+		if strings.TrimSpace(req.SpinOffSymbol) == "" {
+			return "spin_off_symbol is required for a spin-off"
+		}
+		if req.RatioFrom <= 0 || req.RatioTo <= 0 {
+			return "ratio_from and ratio_to must be positive"
+		}
+		if req.SpinOffBasisPercent <= 0 || req.SpinOffBasisPercent >= 1 {
+			return "spin_off_basis_percent must be between 0 and 1"
+		}
+	case "special_dividend":
+		if req.CashAmount <= 0 {
+			return "cash_amount must be positive"
+		}
+	default:
+		return "Invalid type. Must be one of split, reverse_split, ticker_change, spin_off, special_dividend"
+	}
+	return ""
+}
+
+func CreateCorporateAction(c *gin.Context) {
+	userID := c.Param("id")
+	var req CreateCorporateActionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateCorporateAction(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var stock models.Stock
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", req.StockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
+	action := models.CorporateAction{
+		UserID:              stock.UserID,
+		StockID:             stock.ID,
+		PortfolioID:         stock.PortfolioID,
+		Type:                req.Type,
+		EffectiveDate:       req.EffectiveDate,
+		RatioFrom:           req.RatioFrom,
+		RatioTo:             req.RatioTo,
+		NewSymbol:           strings.ToUpper(strings.TrimSpace(req.NewSymbol)),
+		SpinOffSymbol:       strings.ToUpper(strings.TrimSpace(req.SpinOffSymbol)),
+		SpinOffBasisPercent: req.SpinOffBasisPercent,
+		CashAmount:          req.CashAmount,
+		Status:              "pending",
+	}
+
+	if err := dbFor(c).Create(&action).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create corporate action"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, action)
+}
+
+var corporateActionList = query.Spec{
+	Sorts: map[string]string{
+		"effective_date": "effective_date",
+		"created_at":     "created_at",
+	},
+	DefaultSort: "-effective_date",
+	Filters: []query.Filter{
+		query.Equals("stock_id", "stock_id"),
+		query.Equals("portfolio_id", "portfolio_id"),
+		query.Equals("type", "type"),
+		query.Equals("status", "status"),
+		query.DateRange("effective", "effective_date"),
+	},
+}
+
+func GetCorporateActions(c *gin.Context) {
+	userID := c.Param("id")
+
+	var actions []models.CorporateAction
+	if !findList(c, corporateActionList, dbFor(c).Where("portfolio_id IN (?)", memberPortfolioIDs(userID)),
+		&actions, "Failed to fetch corporate actions") {
+		return
+	}
+
+	c.JSON(http.StatusOK, actions)
+}
+
+func GetCorporateAction(c *gin.Context) {
+	userID := c.Param("id")
+	actionID := c.Param("actionId")
+
+	var action models.CorporateAction
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", actionID, memberPortfolioIDs(userID)).
+		Preload("Adjustments").
+		First(&action).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, action)
+}
+
+func DeleteCorporateAction(c *gin.Context) {
+	userID := c.Param("id")
+	actionID := c.Param("actionId")
+
+	var action models.CorporateAction
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", actionID, memberPortfolioIDs(userID)).First(&action).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, action.PortfolioID, editorRoles...) {
+		return
+	}
+
+	if action.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Applied corporate actions cannot be deleted"})
+		return
+	}
+
+	if err := dbFor(c).Delete(&action).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete corporate action"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Corporate action deleted successfully"})
+}
+
+func ApplyCorporateAction(c *gin.Context) {
+	userID := c.Param("id")
+	actionID := c.Param("actionId")
+
+	var action models.CorporateAction
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", actionID, memberPortfolioIDs(userID)).First(&action).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Corporate action not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, action.PortfolioID, editorRoles...) {
+		return
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", action.ID).
+			First(&action).Error; err != nil {
+			return err
+		}
+
+		if action.Status != "pending" {
+			return errCorporateActionApplied
+		}
+
+		if err := applyCorporateAction(tx, c, &action); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		action.Status = "applied"
+		action.AppliedAt = &now
+		return tx.Save(&action).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Corporate action not found"})
+		return
+	}
+	if errors.Is(err, errCorporateActionApplied) || errors.Is(err, errSpinOffOpenCalls) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate action"})
+		return
+	}
+
+	dbFor(c).Preload("Adjustments").First(&action, "id = ?", action.ID)
+
+	c.JSON(http.StatusOK, action)
+}
+
+// applyCorporateAction adjusts the stock and its open calls, recording each
+// change both as an adjustment of the action and in the audit log.
+func applyCorporateAction(tx *gorm.DB, c *gin.Context, action *models.CorporateAction) error {
+	var stock models.Stock
+	if err := tx.Where("id = ?", action.StockID).First(&stock).Error; err != nil {
+		return err
+	}
+
+	var calls []models.CoveredCall
+	if err := tx.Where("stock_id = ? AND status IN ?", stock.ID, []string{"pending", "active"}).
+		Find(&calls).Error; err != nil {
+		return err
+	}
+
+	stockBefore := stockSnapshot(stock)
+	stockAudit := audit.State(&stock)
+	callsBefore := make([]gin.H, len(calls))
+	callsAudit := make([]map[string]any, len(calls))
+	for i := range calls {
+		callsBefore[i] = coveredCallSnapshot(calls[i])
+		callsAudit[i] = audit.State(&calls[i])
+	}
+
+	switch action.Type {
+	case "split", "reverse_split":
+		ratio := action.Ratio()
+		stock.AdjustForSplit(ratio)
+		for i := range calls {
+			calls[i].AdjustForSplit(ratio)
+		}
+	case "ticker_change":
+		stock.Symbol = action.NewSymbol
+	case "spin_off":
+		// OCC adds the spun-off shares to open contracts' deliverable, which
+		// calls here can't represent.
+		if len(calls) > 0 {
+			return errSpinOffOpenCalls
+		}
+		spinOff := models.Stock{
+			UserID:      stock.UserID,
+			PortfolioID: stock.PortfolioID,
+			Symbol:      action.SpinOffSymbol,
+			Shares:      stock.Shares * action.Ratio(),
+		}
+		if spinOff.Shares > 0 {
+			spinOff.Basis = stock.Basis * stock.Shares * action.SpinOffBasisPercent / spinOff.Shares
+		}
+		stock.Basis *= 1 - action.SpinOffBasisPercent
+
+		if err := tx.Create(&spinOff).Error; err != nil {
+			return err
+		}
+		if err := recordAdjustment(tx, action.ID, "stock", spinOff.ID, nil, stockSnapshot(spinOff)); err != nil {
+			return err
+		}
+		if err := recordCausedAudit(tx, c, models.AuditCauseCorporateAction, action.ID, models.AuditCreate, &spinOff, nil); err != nil {
+			return err
+		}
+	case "special_dividend":
+		// OCC only adjusts strikes for special dividends of at least $12.50 per contract.
+		if action.CashAmount*100 >= 12.5 {
+			for i := range calls {
+				calls[i].StrikePrice -= action.CashAmount
+			}
+		}
+	}
+
+	if err := tx.Save(&stock).Error; err != nil {
+		return err
+	}
+	if err := recordAdjustment(tx, action.ID, "stock", stock.ID, stockBefore, stockSnapshot(stock)); err != nil {
+		return err
+	}
+	if err := recordCausedAudit(tx, c, models.AuditCauseCorporateAction, action.ID, models.AuditUpdate, &stock, stockAudit); err != nil {
+		return err
+	}
+
+	for i := range calls {
+		after := coveredCallSnapshot(calls[i])
+		if after["strike_price"] == callsBefore[i]["strike_price"] && after["contracts"] == callsBefore[i]["contracts"] &&
+			after["deliverable"] == callsBefore[i]["deliverable"] {
+			continue
+		}
+		if err := tx.Save(&calls[i]).Error; err != nil {
+			return err
+		}
+		if err := recordAdjustment(tx, action.ID, "covered_call", calls[i].ID, callsBefore[i], after); err != nil {
+			return err
+		}
+		if err := recordCausedAudit(tx, c, models.AuditCauseCorporateAction, action.ID, models.AuditUpdate, &calls[i], callsAudit[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recordAdjustment(tx *gorm.DB, actionID, entityType, entityID string, before, after gin.H) error {
+	adjustment := models.CorporateActionAdjustment{
+		CorporateActionID: actionID,
+		EntityType:        entityType,
+		EntityID:          entityID,
+	}
+
+	var err error
+	if before != nil {
+		if adjustment.Before, err = models.NewJSON(before); err != nil {
+			return err
+		}
+	}
+	if adjustment.After, err = models.NewJSON(after); err != nil {
+		return err
+	}
+
+	return tx.Create(&adjustment).Error
+}
+
+func stockSnapshot(stock models.Stock) gin.H {
+	return gin.H{
+		"symbol": stock.Symbol,
+		"shares": stock.Shares,
+		"basis":  stock.Basis,
+	}
+}
+
+func coveredCallSnapshot(call models.CoveredCall) gin.H {
+	return gin.H{
+		"strike_price":     call.StrikePrice,
+		"premium_received": call.PremiumReceived,
+		"contracts":        call.Contracts,
+		"deliverable":      call.Deliverable,
+		"shares_covered":   call.SharesCovered,
+	}
+}
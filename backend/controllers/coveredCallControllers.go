@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"deltra-backend/audit"
 	"deltra-backend/config"
 	"deltra-backend/models"
-	"fmt"
+	"deltra-backend/query"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreateCoveredCallRequest struct {
@@ -16,14 +20,19 @@ type CreateCoveredCallRequest struct {
 	PremiumReceived float64   `json:"premium_received" binding:"required"`
 	Contracts       int       `json:"contracts" binding:"required"`
 	ExpirationDate  time.Time `json:"expiration_date" binding:"required"`
+	Fees            *float64  `json:"fees,omitempty"`
 }
 
+var closedCallStatuses = map[string]bool{"assigned": true, "bought_back": true}
+
 type UpdateCoveredCallRequest struct {
 	Status          string     `json:"status,omitempty"`
 	AssignmentDate  *time.Time `json:"assignment_date,omitempty"`
 	AssignmentPrice *float64   `json:"assignment_price,omitempty"`
 	BuybackDate     *time.Time `json:"buyback_date,omitempty"`
 	BuybackPremium  *float64   `json:"buyback_premium,omitempty"`
+	BuybackFees     *float64   `json:"buyback_fees,omitempty"`
+	AssignmentFees  *float64   `json:"assignment_fees,omitempty"`
 }
 
 func CreateCoveredCall(c *gin.Context) {
@@ -31,58 +40,101 @@ func CreateCoveredCall(c *gin.Context) {
 	var req CreateCoveredCallRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		fmt.Printf("Covered call creation binding error: %v\n", err)
+		slog.DebugContext(c.Request.Context(), "Covered call creation binding error", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if req.Fees != nil && *req.Fees < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return
+	}
+
 	var stock models.Stock
-	if err := config.DB.Where("id = ? AND user_id = ?", req.StockID, userID).First(&stock).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", req.StockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
 		return
 	}
 
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
+	deliverable := 100
 	totalPremium := req.PremiumReceived * float64(req.Contracts) * 100
-	sharesCovered := req.Contracts * 100
+	sharesCovered := req.Contracts * deliverable
 
 	if float64(sharesCovered) > stock.Shares {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient shares to cover the call"})
 		return
 	}
 
+	openFees := portfolioFeeSchedule(stock.PortfolioID).OptionTrade(req.Contracts)
+	if req.Fees != nil {
+		openFees = *req.Fees
+	}
+
 	coveredCall := models.CoveredCall{
 		StockID:         req.StockID,
-		UserID:          userID,
+		UserID:          stock.UserID,
 		PortfolioID:     stock.PortfolioID,
 		StrikePrice:     req.StrikePrice,
 		PremiumReceived: req.PremiumReceived,
 		Contracts:       req.Contracts,
+		Deliverable:     deliverable,
 		ExpirationDate:  req.ExpirationDate,
 		Status:          "pending", // Start in pending state
 		TotalPremium:    totalPremium,
 		SharesCovered:   sharesCovered,
+		OpenFees:        openFees,
 	}
 
-	if err := config.DB.Create(&coveredCall).Error; err != nil {
+	if err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&coveredCall).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditCreate, &coveredCall, nil)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create covered call"})
 		return
 	}
 
-	config.DB.Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
+	dbFor(c).Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
 
 	c.JSON(http.StatusCreated, coveredCall)
 }
 
+var coveredCallList = query.Spec{
+	Sorts: map[string]string{
+		"created_at":       "created_at",
+		"expiration_date":  "expiration_date",
+		"strike_price":     "strike_price",
+		"premium_received": "premium_received",
+		"total_premium":    "total_premium",
+		"contracts":        "contracts",
+		"status":           "status",
+	},
+	DefaultSort: "-created_at",
+	Filters: []query.Filter{
+		query.Equals("status", "status"),
+		query.Custom("symbol", func(db *gorm.DB, symbols []string) *gorm.DB {
+			return db.Where("stock_id IN (?)", config.DB.Model(&models.Stock{}).
+				Select("id").
+				Where("UPPER(symbol) IN ?", upperAll(symbols)))
+		}),
+		query.Equals("portfolio_id", "portfolio_id"),
+		query.Equals("stock_id", "stock_id"),
+		query.DateRange("expiration", "expiration_date"),
+		query.DateRange("created", "created_at"),
+	},
+}
+
 func GetCoveredCalls(c *gin.Context) {
 	userID := c.Param("id")
 
 	var coveredCalls []models.CoveredCall
-	if err := config.DB.Where("user_id = ?", userID).
-		Preload("Stock").
-		Preload("Portfolio").
-		Order("created_at DESC").
-		Find(&coveredCalls).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch covered calls"})
+	if !findList(c, coveredCallList, dbFor(c).Where("portfolio_id IN (?)", memberPortfolioIDs(userID)),
+		&coveredCalls, "Failed to fetch covered calls", "Stock", "Portfolio") {
 		return
 	}
 
@@ -94,7 +146,7 @@ func GetCoveredCall(c *gin.Context) {
 	callID := c.Param("callId")
 
 	var coveredCall models.CoveredCall
-	if err := config.DB.Where("id = ? AND user_id = ?", callID, userID).
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", callID, memberPortfolioIDs(userID)).
 		Preload("Stock").
 		Preload("Portfolio").
 		First(&coveredCall).Error; err != nil {
@@ -115,12 +167,30 @@ func UpdateCoveredCall(c *gin.Context) {
 		return
 	}
 
+	if (req.BuybackFees != nil && *req.BuybackFees < 0) || (req.AssignmentFees != nil && *req.AssignmentFees < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return
+	}
+
 	var coveredCall models.CoveredCall
-	if err := config.DB.Where("id = ? AND user_id = ?", callID, userID).First(&coveredCall).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", callID, memberPortfolioIDs(userID)).First(&coveredCall).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Covered call not found"})
 		return
 	}
 
+	if !requirePortfolioRole(c, userID, coveredCall.PortfolioID, editorRoles...) {
+		return
+	}
+
+	// Closing a call moved cash and, on assignment, shares; reopening it
+	// would count them twice.
+	if closedCallStatuses[coveredCall.Status] && req.Status != "" && req.Status != coveredCall.Status {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Assigned or bought-back calls can't change status"})
+		return
+	}
+
+	before := audit.State(&coveredCall)
+	previousStatus := coveredCall.Status
 	if req.Status != "" {
 		coveredCall.Status = req.Status
 	}
@@ -136,13 +206,45 @@ func UpdateCoveredCall(c *gin.Context) {
 	if req.BuybackPremium != nil {
 		coveredCall.BuybackPremium = req.BuybackPremium
 	}
+	if req.BuybackFees != nil {
+		coveredCall.BuybackFees = *req.BuybackFees
+	} else if coveredCall.Status == "bought_back" && previousStatus != "bought_back" {
+		coveredCall.BuybackFees = portfolioFeeSchedule(coveredCall.PortfolioID).OptionTrade(coveredCall.Contracts)
+	}
+	if req.AssignmentFees != nil {
+		coveredCall.AssignmentFees = *req.AssignmentFees
+	} else if coveredCall.Status == "assigned" && previousStatus != "assigned" {
+		coveredCall.AssignmentFees = portfolioFeeSchedule(coveredCall.PortfolioID).Assignment()
+	}
 
-	if err := config.DB.Save(&coveredCall).Error; err != nil {
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if coveredCall.Status == "assigned" {
+			if err := deliverAssignedShares(tx, c, &coveredCall); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&coveredCall).Error; err != nil {
+			return err
+		}
+		if err := recordCallCashFlow(tx, coveredCall, previousStatus); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditUpdate, &coveredCall, before)
+	})
+	if errors.Is(err, errInsufficientCash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient cash to buy back covered call"})
+		return
+	}
+	if errors.Is(err, errInsufficientShares) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The stock no longer holds the shares this call covers"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update covered call"})
 		return
 	}
 
-	config.DB.Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
+	dbFor(c).Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
 
 	c.JSON(http.StatusOK, coveredCall)
 }
@@ -152,12 +254,16 @@ func DeleteCoveredCall(c *gin.Context) {
 	callID := c.Param("callId")
 
 	var coveredCall models.CoveredCall
-	if err := config.DB.Where("id = ? AND user_id = ?", callID, userID).First(&coveredCall).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", callID, memberPortfolioIDs(userID)).First(&coveredCall).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Covered call not found"})
 		return
 	}
 
-	if err := config.DB.Delete(&coveredCall).Error; err != nil {
+	if !requirePortfolioRole(c, userID, coveredCall.PortfolioID, editorRoles...) {
+		return
+	}
+
+	if err := deleteAudited(c, &coveredCall); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete covered call"})
 		return
 	}
@@ -170,18 +276,14 @@ func GetStockCoveredCalls(c *gin.Context) {
 	stockID := c.Param("stockId")
 
 	var stock models.Stock
-	if err := config.DB.Where("id = ? AND user_id = ?", stockID, userID).First(&stock).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
 		return
 	}
 
 	var coveredCalls []models.CoveredCall
-	if err := config.DB.Where("stock_id = ? AND user_id = ?", stockID, userID).
-		Preload("Stock").
-		Preload("Portfolio").
-		Order("created_at DESC").
-		Find(&coveredCalls).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch covered calls"})
+	if !findList(c, coveredCallList, dbFor(c).Where("stock_id = ?", stock.ID),
+		&coveredCalls, "Failed to fetch covered calls", "Stock", "Portfolio") {
 		return
 	}
 
@@ -207,24 +309,38 @@ func ActivateCoveredCall(c *gin.Context) {
 	callID := c.Param("callId")
 
 	var coveredCall models.CoveredCall
-	if err := config.DB.Where("id = ? AND user_id = ?", callID, userID).First(&coveredCall).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", callID, memberPortfolioIDs(userID)).First(&coveredCall).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Covered call not found"})
 		return
 	}
 
+	if !requirePortfolioRole(c, userID, coveredCall.PortfolioID, editorRoles...) {
+		return
+	}
+
 	if coveredCall.Status != "pending" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending calls can be activated"})
 		return
 	}
 
+	before := audit.State(&coveredCall)
 	coveredCall.Status = "active"
-	
-	if err := config.DB.Save(&coveredCall).Error; err != nil {
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&coveredCall).Error; err != nil {
+			return err
+		}
+		if err := recordCallCashFlow(tx, coveredCall, "pending"); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditUpdate, &coveredCall, before)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate covered call"})
 		return
 	}
 
-	config.DB.Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
+	dbFor(c).Preload("Stock").Preload("Portfolio").First(&coveredCall, coveredCall.ID)
 
 	c.JSON(http.StatusOK, coveredCall)
 }
\ No newline at end of file
@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"deltra-backend/config"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dbFor scopes queries to the request, so they are logged with its request
+// and user IDs and abandoned if the client goes away.
+func dbFor(c *gin.Context) *gorm.DB {
+	return config.DB.WithContext(c.Request.Context())
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"deltra-backend/audit"
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateDividendRequest struct {
+	ExDate           time.Time  `json:"ex_date" binding:"required"`
+	PayDate          *time.Time `json:"pay_date,omitempty"`
+	AmountPerShare   float64    `json:"amount_per_share" binding:"required"`
+	Shares           *float64   `json:"shares,omitempty"`
+	Qualified        bool       `json:"qualified"`
+	ReinvestedShares float64    `json:"reinvested_shares"`
+}
+
+type DividendRisk struct {
+	CoveredCall         models.CoveredCall `json:"covered_call"`
+	Dividend            models.Dividend    `json:"dividend"`
+	DaysUntilExDate     int                `json:"days_until_ex_date"`
+	DividendPerContract float64            `json:"dividend_per_contract"`
+}
+
+var dividendList = query.Spec{
+	Sorts: map[string]string{
+		"ex_date":    "ex_date",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-ex_date",
+	Filters: []query.Filter{
+		query.DateRange("ex", "ex_date"),
+	},
+}
+
+func GetDividends(c *gin.Context) {
+	userID := c.Param("id")
+	stockID := c.Param("stockId")
+
+	var dividends []models.Dividend
+	if !findList(c, dividendList, dbFor(c).Where("stock_id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)),
+		&dividends, "Failed to fetch dividends") {
+		return
+	}
+
+	c.JSON(http.StatusOK, dividends)
+}
+
+func CreateDividend(c *gin.Context) {
+	userID := c.Param("id")
+	stockID := c.Param("stockId")
+	var req CreateDividendRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AmountPerShare <= 0 || req.ReinvestedShares < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dividend amounts must be positive"})
+		return
+	}
+
+	var stock models.Stock
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
+	var dividend models.Dividend
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", stock.ID).First(&stock).Error; err != nil {
+			return err
+		}
+
+		shares := stock.Shares
+		if req.Shares != nil {
+			shares = *req.Shares
+		}
+
+		dividend = models.Dividend{
+			UserID:           stock.UserID,
+			StockID:          stock.ID,
+			PortfolioID:      stock.PortfolioID,
+			ExDate:           req.ExDate,
+			PayDate:          req.PayDate,
+			AmountPerShare:   req.AmountPerShare,
+			Shares:           shares,
+			TotalAmount:      req.AmountPerShare * shares,
+			Qualified:        req.Qualified,
+			ReinvestedShares: req.ReinvestedShares,
+		}
+
+		// Reinvested shares are only bought on the pay date; until then the
+		// daily reinvestment job holds them back.
+		reinvest := dividend.ReinvestmentDue(time.Now())
+		before := audit.State(&stock)
+		if reinvest {
+			dividend.Reinvest(&stock, time.Now())
+		}
+
+		if err := tx.Create(&dividend).Error; err != nil {
+			return err
+		}
+		if !reinvest {
+			return nil
+		}
+		if err := tx.Save(&stock).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditUpdate, &stock, before)
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record dividend"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dividend)
+}
+
+func DeleteDividend(c *gin.Context) {
+	userID := c.Param("id")
+	stockID := c.Param("stockId")
+	dividendID := c.Param("dividendId")
+
+	var dividend models.Dividend
+	if err := dbFor(c).Where("id = ? AND stock_id = ? AND portfolio_id IN (?)", dividendID, stockID, memberPortfolioIDs(userID)).
+		First(&dividend).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dividend not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, dividend.PortfolioID, editorRoles...) {
+		return
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+
+		if dividend.ReinvestedAt != nil {
+			var stock models.Stock
+			if err := tx.Where("id = ?", dividend.StockID).First(&stock).Error; err != nil {
+				return err
+			}
+
+			before := audit.State(&stock)
+			dividend.Unreinvest(&stock)
+
+			if err := tx.Save(&stock).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(tx, c, models.AuditUpdate, &stock, before); err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&dividend).Error
+	})
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dividend not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dividend"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dividend deleted successfully"})
+}
+
+func GetDividendAssignmentRisks(c *gin.Context) {
+	userID := c.Param("id")
+	now := time.Now()
+
+	var dividends []models.Dividend
+	if err := dbFor(c).Where("portfolio_id IN (?) AND ex_date > ? AND ex_date <= ?", memberPortfolioIDs(userID), now, now.Add(models.EarlyAssignmentWindow)).
+		Order("ex_date ASC").
+		Find(&dividends).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dividends"})
+		return
+	}
+
+	risks := []DividendRisk{}
+	for _, dividend := range dividends {
+		var calls []models.CoveredCall
+		if err := dbFor(c).Where("stock_id = ? AND status = ?", dividend.StockID, "active").
+			Preload("Stock").
+			Find(&calls).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch covered calls"})
+			return
+		}
+
+		for _, call := range calls {
+			if !dividend.AtRiskOfEarlyAssignment(call, now) {
+				continue
+			}
+			call.EarlyAssignmentRisk = true
+			risks = append(risks, DividendRisk{
+				CoveredCall:         call,
+				Dividend:            dividend,
+				DaysUntilExDate:     int(dividend.ExDate.Sub(now).Hours() / 24),
+				DividendPerContract: dividend.AmountPerShare * float64(call.Deliverable),
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, risks)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeeScheduleRequest struct {
+	StockTradeFee  float64 `json:"stock_trade_fee"`
+	PerShareFee    float64 `json:"per_share_fee"`
+	OptionTradeFee float64 `json:"option_trade_fee"`
+	PerContractFee float64 `json:"per_contract_fee"`
+	AssignmentFee  float64 `json:"assignment_fee"`
+}
+
+func portfolioFeeSchedule(portfolioID string) *models.FeeSchedule {
+	if portfolioID == "" {
+		return nil
+	}
+
+	var schedule models.FeeSchedule
+	if err := config.DB.Where("portfolio_id = ?", portfolioID).First(&schedule).Error; err != nil {
+		return nil
+	}
+	return &schedule
+}
+
+func GetFeeSchedule(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var schedule models.FeeSchedule
+	if err := dbFor(c).Where("portfolio_id = ? AND portfolio_id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&schedule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fee schedule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func UpdateFeeSchedule(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, portfolio.ID, editorRoles...) {
+		return
+	}
+
+	var req FeeScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.StockTradeFee < 0 || req.PerShareFee < 0 || req.OptionTradeFee < 0 || req.PerContractFee < 0 || req.AssignmentFee < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return
+	}
+
+	schedule := models.FeeSchedule{PortfolioID: portfolio.ID, UserID: portfolio.UserID}
+	dbFor(c).Where("portfolio_id = ?", portfolio.ID).First(&schedule)
+
+	schedule.StockTradeFee = req.StockTradeFee
+	schedule.PerShareFee = req.PerShareFee
+	schedule.OptionTradeFee = req.OptionTradeFee
+	schedule.PerContractFee = req.PerContractFee
+	schedule.AssignmentFee = req.AssignmentFee
+
+	if err := dbFor(c).Save(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save fee schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
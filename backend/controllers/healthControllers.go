@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"context"
+	"deltra-backend/config"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// Healthz reports that the process is up. It checks nothing else, so a slow
+// database doesn't get the server restarted.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the server can take traffic: the database answers
+// and its schema is migrated.
+func Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	if problems := config.CheckReady(ctx); len(problems) > 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": problems})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
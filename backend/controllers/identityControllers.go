@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"deltra-backend/auth"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LinkIdentityRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	IDToken  string `json:"idToken" binding:"required"`
+	Nonce    string `json:"nonce" binding:"required"`
+}
+
+// userOwnedModels lists every table keyed by user_id that moves with an
+// account merge.
+var userOwnedModels = []any{
+	&models.Portfolio{},
+	&models.Stock{},
+	&models.CoveredCall{},
+	&models.CorporateAction{},
+	&models.Dividend{},
+	&models.FeeSchedule{},
+	&models.CashTransaction{},
+	&models.PortfolioSnapshot{},
+	&models.StockSnapshot{},
+	&models.AuditEvent{},
+	&models.ShareLink{},
+	&models.APIKey{},
+	&models.CalendarFeed{},
+	&models.AlertRule{},
+	&models.AlertEvent{},
+	&models.Notification{},
+	&models.NotificationDelivery{},
+	&models.PushDevice{},
+	&models.WebhookEndpoint{},
+	&models.WebhookDelivery{},
+	&models.Identity{},
+	&models.PortfolioMember{},
+}
+
+// verifyLinkRequest checks the ID token for an identity the signed-in user
+// wants to link. Linking changes how the account can be accessed, so it
+// requires an interactive session rather than an API key.
+func verifyLinkRequest(c *gin.Context) (auth.Principal, auth.Identity, bool) {
+	principal, _ := middleware.AuthPrincipal(c)
+	if principal.APIKeyID != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot manage sign-in methods"})
+		return principal, auth.Identity{}, false
+	}
+
+	var req LinkIdentityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return principal, auth.Identity{}, false
+	}
+
+	identity, err := auth.VerifyIDToken(c.Request.Context(), req.Provider, req.IDToken, req.Nonce)
+	if errors.Is(err, auth.ErrUnknownProvider) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider. Must be 'google' or 'apple'"})
+		return principal, auth.Identity{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return principal, auth.Identity{}, false
+	}
+
+	return principal, identity, true
+}
+
+func GetIdentities(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var identities []models.Identity
+	if err := dbFor(c).Where("user_id = ?", principal.UserID).
+		Order("created_at ASC").
+		Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+func LinkIdentity(c *gin.Context) {
+	principal, identity, ok := verifyLinkRequest(c)
+	if !ok {
+		return
+	}
+
+	var existing models.Identity
+	err := dbFor(c).Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID == principal.UserID {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "This sign-in method belongs to another account",
+			"merge_required": true,
+		})
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	linked := models.Identity{
+		UserID:     principal.UserID,
+		Provider:   identity.Provider,
+		Subject:    identity.Subject,
+		Email:      identity.Email,
+		LastUsedAt: time.Now(),
+	}
+	if err := dbFor(c).Create(&linked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, linked)
+}
+
+func UnlinkIdentity(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+	if principal.APIKeyID != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot manage sign-in methods"})
+		return
+	}
+	identityID := c.Param("identityId")
+
+	errLastIdentity := errors.New("last identity")
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		var identities []models.Identity
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", principal.UserID).
+			Find(&identities).Error; err != nil {
+			return err
+		}
+
+		for _, identity := range identities {
+			if identity.ID != identityID {
+				continue
+			}
+			if len(identities) == 1 {
+				return errLastIdentity
+			}
+			return tx.Delete(&identity).Error
+		}
+		return gorm.ErrRecordNotFound
+	})
+
+	if errors.Is(err, errLastIdentity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot unlink your only sign-in method"})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
+
+// roleRank orders membership roles from least to most access.
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// outranks reports whether membership a grants more than b, a membership of
+// the same portfolio: an accepted membership beats an open or declined
+// invitation, then the higher role wins.
+func outranks(a, b models.PortfolioMember) bool {
+	aAccepted := a.Status == models.MembershipAccepted
+	if aAccepted != (b.Status == models.MembershipAccepted) {
+		return aAccepted
+	}
+	return roleRank[a.Role] > roleRank[b.Role]
+}
+
+// MergeAccount folds the account that owns the presented identity into the
+// signed-in account. Presenting a fresh ID token for the other account proves
+// the caller controls both. The other account's sessions are revoked and the
+// account is deleted once everything it owned has moved.
+func MergeAccount(c *gin.Context) {
+	principal, identity, ok := verifyLinkRequest(c)
+	if !ok {
+		return
+	}
+
+	var linked models.Identity
+	if err := dbFor(c).Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).
+		First(&linked).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No account uses this sign-in method, link it instead"})
+		return
+	}
+	if linked.UserID == principal.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This sign-in method is already linked to your account"})
+		return
+	}
+
+	sourceID := linked.UserID
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		// Memberships move too. Where both accounts belong to the same
+		// portfolio only the stronger membership is kept, so an owner
+		// merging into a viewer doesn't leave the portfolio without one.
+		var memberships []models.PortfolioMember
+		if err := tx.Where("user_id IN ?", []string{sourceID, principal.UserID}).
+			Find(&memberships).Error; err != nil {
+			return err
+		}
+		kept := map[string]models.PortfolioMember{}
+		var dropped []string
+		for _, membership := range memberships {
+			other, ok := kept[membership.PortfolioID]
+			switch {
+			case !ok:
+				kept[membership.PortfolioID] = membership
+			case outranks(membership, other):
+				kept[membership.PortfolioID] = membership
+				dropped = append(dropped, other.ID)
+			default:
+				dropped = append(dropped, membership.ID)
+			}
+		}
+		if len(dropped) > 0 {
+			if err := tx.Delete(&models.PortfolioMember{}, "id IN ?", dropped).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, model := range userOwnedModels {
+			if err := tx.Unscoped().Model(model).Where("user_id = ?", sourceID).
+				Update("user_id", principal.UserID).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.AuditEvent{}).Where("actor_id = ?", sourceID).
+			Update("actor_id", principal.UserID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", sourceID).
+			Updates(map[string]any{"revoked_at": time.Now(), "revoked_reason": "account_merged"}).Error; err != nil {
+			return err
+		}
+
+		// Only one notification webhook per user; the target's wins.
+		if err := tx.Where("user_id = ?", sourceID).Delete(&models.NotificationWebhook{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.User{}, "id = ?", sourceID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge accounts"})
+		return
+	}
+
+	var user models.User
+	if err := dbFor(c).Where("id = ?", principal.UserID).Preload("Identities").First(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"deltra-backend/models"
+	"testing"
+)
+
+func TestOutranks(t *testing.T) {
+	member := func(role, status string) models.PortfolioMember {
+		return models.PortfolioMember{Role: role, Status: status}
+	}
+	owner := member(models.RoleOwner, models.MembershipAccepted)
+	editor := member(models.RoleEditor, models.MembershipAccepted)
+	viewer := member(models.RoleViewer, models.MembershipAccepted)
+
+	tests := []struct {
+		name string
+		a, b models.PortfolioMember
+		want bool
+	}{
+		{name: "owner over viewer", a: owner, b: viewer, want: true},
+		{name: "viewer under owner", a: viewer, b: owner, want: false},
+		{name: "owner over editor", a: owner, b: editor, want: true},
+		{name: "editor over viewer", a: editor, b: viewer, want: true},
+		{name: "same role", a: viewer, b: viewer, want: false},
+		{name: "accepted viewer over pending editor", a: viewer, b: member(models.RoleEditor, models.MembershipPending), want: true},
+		{name: "declined editor under accepted viewer", a: member(models.RoleEditor, models.MembershipDeclined), b: viewer, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outranks(tt.a, tt.b); got != tt.want {
+				t.Errorf("outranks(%s %s, %s %s) = %v, want %v", tt.a.Status, tt.a.Role, tt.b.Status, tt.b.Role, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"deltra-backend/config"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var editorRoles = []string{models.RoleOwner, models.RoleEditor}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// memberPortfolioIDs selects the portfolios a user has accepted membership
+// of, optionally limited to some roles. Use it as a subquery in place of
+// filtering on user_id.
+func memberPortfolioIDs(userID string, roles ...string) *gorm.DB {
+	query := config.DB.Model(&models.PortfolioMember{}).
+		Select("portfolio_id").
+		Where("user_id = ? AND status = ?", userID, models.MembershipAccepted).
+		Where("portfolio_id IN (?)", config.DB.Model(&models.Portfolio{}).Select("id"))
+	if len(roles) > 0 {
+		query = query.Where("role IN ?", roles)
+	}
+	return query
+}
+
+func portfolioRole(userID, portfolioID string) string {
+	var member models.PortfolioMember
+	if err := config.DB.Where("portfolio_id = ? AND user_id = ? AND status = ?", portfolioID, userID, models.MembershipAccepted).
+		First(&member).Error; err != nil {
+		return ""
+	}
+	return member.Role
+}
+
+// requirePortfolioRole responds with 403 unless the user holds one of roles
+// on the portfolio.
+func requirePortfolioRole(c *gin.Context, userID, portfolioID string, roles ...string) bool {
+	role := portfolioRole(userID, portfolioID)
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to modify this portfolio"})
+	return false
+}
+
+func addPortfolioOwner(tx *gorm.DB, portfolio models.Portfolio, user models.User) error {
+	now := time.Now()
+	return tx.Create(&models.PortfolioMember{
+		PortfolioID: portfolio.ID,
+		UserID:      &user.ID,
+		Email:       strings.ToLower(user.Email),
+		Role:        models.RoleOwner,
+		Status:      models.MembershipAccepted,
+		AcceptedAt:  &now,
+	}).Error
+}
+
+func GetPortfolioMembers(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	if portfolioRole(userID, portfolioID) == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	var members []models.PortfolioMember
+	if err := dbFor(c).Where("portfolio_id = ? AND status <> ?", portfolioID, models.MembershipDeclined).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func InvitePortfolioMember(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	if !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidMemberRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'editor' or 'viewer'"})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var member models.PortfolioMember
+	err := dbFor(c).Where("portfolio_id = ? AND email = ?", portfolioID, email).First(&member).Error
+	switch {
+	case err == nil && member.Status != models.MembershipDeclined:
+		c.JSON(http.StatusConflict, gin.H{"error": "This email has already been invited"})
+		return
+	case err == nil:
+		// Declined invitations can be sent again.
+		member.Role = req.Role
+		member.Status = models.MembershipPending
+		member.InvitedBy = &userID
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.PortfolioMember{
+			PortfolioID: portfolioID,
+			Email:       email,
+			Role:        req.Role,
+			Status:      models.MembershipPending,
+			InvitedBy:   &userID,
+		}
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite member"})
+		return
+	}
+
+	if err := dbFor(c).Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite member"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, member)
+}
+
+func UpdatePortfolioMember(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+	memberID := c.Param("memberId")
+
+	if !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidMemberRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'editor' or 'viewer'"})
+		return
+	}
+
+	var member models.PortfolioMember
+	if err := dbFor(c).Where("id = ? AND portfolio_id = ?", memberID, portfolioID).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if member.Role == models.RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner's role cannot be changed"})
+		return
+	}
+
+	member.Role = req.Role
+	if err := dbFor(c).Save(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// RemovePortfolioMember lets the owner remove anyone else, and lets members
+// leave a portfolio shared with them.
+func RemovePortfolioMember(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+	memberID := c.Param("memberId")
+
+	var member models.PortfolioMember
+	if err := dbFor(c).Where("id = ? AND portfolio_id = ?", memberID, portfolioID).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	leaving := member.UserID != nil && *member.UserID == userID
+	if !leaving && !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+	if member.Role == models.RoleOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot be removed, delete the portfolio instead"})
+		return
+	}
+
+	if err := dbFor(c).Delete(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func GetInvitations(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var user models.User
+	if err := dbFor(c).Where("id = ?", principal.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	// Invitations go to an email address, so only its verified owner sees them.
+	invitations := []models.PortfolioMember{}
+	if !user.EmailVerified {
+		c.JSON(http.StatusOK, invitations)
+		return
+	}
+
+	if err := dbFor(c).Where("email = ? AND status = ?", strings.ToLower(user.Email), models.MembershipPending).
+		Preload("Portfolio").
+		Order("created_at DESC").
+		Find(&invitations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func AcceptInvitation(c *gin.Context) {
+	respondToInvitation(c, models.MembershipAccepted)
+}
+
+func DeclineInvitation(c *gin.Context) {
+	respondToInvitation(c, models.MembershipDeclined)
+}
+
+func respondToInvitation(c *gin.Context, status string) {
+	principal, _ := middleware.AuthPrincipal(c)
+	invitationID := c.Param("invitationId")
+
+	var user models.User
+	if err := dbFor(c).Where("id = ?", principal.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign in with a provider that has verified your email to respond to invitations"})
+		return
+	}
+
+	var invitation models.PortfolioMember
+	if err := dbFor(c).Where("id = ? AND email = ? AND status = ?", invitationID, strings.ToLower(user.Email), models.MembershipPending).
+		First(&invitation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+		return
+	}
+
+	invitation.Status = status
+	if status == models.MembershipAccepted {
+		now := time.Now()
+		invitation.UserID = &user.ID
+		invitation.AcceptedAt = &now
+	}
+
+	if err := dbFor(c).Save(&invitation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"deltra-backend/models"
+	"deltra-backend/notify"
+	"deltra-backend/query"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RegisterPushDeviceRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform"`
+	Name     string `json:"name"`
+}
+
+type SetNotificationWebhookRequest struct {
+	URL          string `json:"url" binding:"required"`
+	RotateSecret bool   `json:"rotate_secret"`
+}
+
+type NotificationWebhookResponse struct {
+	models.NotificationWebhook
+	Secret string `json:"secret,omitempty"`
+}
+
+var notificationList = query.Spec{
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-created_at",
+	Filters: []query.Filter{
+		query.Equals("kind", "kind"),
+		query.Custom("unread", func(db *gorm.DB, values []string) *gorm.DB {
+			if values[0] == "true" {
+				return db.Where("read_at IS NULL")
+			}
+			return db.Where("read_at IS NOT NULL")
+		}),
+		query.DateRange("created", "created_at"),
+	},
+}
+
+func GetNotifications(c *gin.Context) {
+	userID := c.Param("id")
+
+	var notifications []models.Notification
+	if !findList(c, notificationList, dbFor(c).Where("user_id = ?", userID),
+		&notifications, "Failed to fetch notifications", "Deliveries") {
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.Param("id")
+	notificationID := c.Param("notificationId")
+
+	var notification models.Notification
+	if err := dbFor(c).Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := dbFor(c).Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.Param("id")
+
+	result := dbFor(c).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// SendTestNotification queues a notification on every channel the user has
+// set up so they can check delivery.
+func SendTestNotification(c *gin.Context) {
+	userID := c.Param("id")
+
+	var notification models.Notification
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		notification, err = notify.Enqueue(tx, userID, notify.Message{
+			Kind:  "test",
+			Title: "Test notification",
+			Body:  "Notifications from Deltra are working.",
+		})
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test notification"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, notification)
+}
+
+func GetPushDevices(c *gin.Context) {
+	userID := c.Param("id")
+
+	var devices []models.PushDevice
+	if err := dbFor(c).Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, devices)
+}
+
+// RegisterPushDevice is called by the app on every launch. A token moves to
+// whoever registered it last, since a device has one signed-in user.
+func RegisterPushDevice(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req RegisterPushDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := strings.TrimSpace(req.Token)
+	if !strings.HasPrefix(token, "ExponentPushToken[") && !strings.HasPrefix(token, "ExpoPushToken[") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token must be an Expo push token"})
+		return
+	}
+
+	device := models.PushDevice{
+		UserID:     userID,
+		Token:      token,
+		Platform:   req.Platform,
+		Name:       req.Name,
+		LastSeenAt: time.Now(),
+	}
+	if err := dbFor(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "name", "last_seen_at"}),
+	}).Create(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	if err := dbFor(c).Where("token = ?", token).First(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+func DeletePushDevice(c *gin.Context) {
+	userID := c.Param("id")
+	deviceID := c.Param("deviceId")
+
+	result := dbFor(c).Where("id = ? AND user_id = ?", deviceID, userID).Delete(&models.PushDevice{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete device"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device deleted successfully"})
+}
+
+func GetNotificationWebhook(c *gin.Context) {
+	userID := c.Param("id")
+
+	var webhook models.NotificationWebhook
+	if err := dbFor(c).Where("user_id = ?", userID).First(&webhook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not set"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// SetNotificationWebhook creates or updates the user's webhook. The signing
+// secret is returned only when it is first generated or rotated.
+func SetNotificationWebhook(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req SetNotificationWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateWebhookURL(req.URL); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var webhook models.NotificationWebhook
+	err := dbFor(c).Where("user_id = ?", userID).First(&webhook).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set webhook"})
+		return
+	}
+
+	response := NotificationWebhookResponse{}
+	if webhook.ID == "" || req.RotateSecret {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set webhook"})
+			return
+		}
+		webhook.Secret = secret
+		response.Secret = secret
+	}
+	webhook.UserID = userID
+	webhook.URL = req.URL
+
+	if err := dbFor(c).Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set webhook"})
+		return
+	}
+
+	response.NotificationWebhook = webhook
+	c.JSON(http.StatusOK, response)
+}
+
+func DeleteNotificationWebhook(c *gin.Context) {
+	userID := c.Param("id")
+
+	if err := dbFor(c).Where("user_id = ?", userID).Delete(&models.NotificationWebhook{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// validateWebhookURL requires HTTPS to a public host, except for localhost
+// while developing with WEBHOOK_ALLOW_PRIVATE_NETWORKS. Hostnames are
+// checked again when dialed, since DNS can change after this.
+func validateWebhookURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return "url must be an absolute URL"
+	}
+
+	host := parsed.Hostname()
+	allowPrivate := notify.AllowPrivateNetworks()
+	if addr, err := netip.ParseAddr(host); err == nil && !notify.PublicAddress(addr) && !allowPrivate {
+		return "url must point to a public address"
+	}
+	if host == "localhost" && !allowPrivate {
+		return "url must point to a public address"
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return ""
+	case "http":
+		if allowPrivate && (host == "localhost" || host == "127.0.0.1") {
+			return ""
+		}
+	}
+	return "url must use https"
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
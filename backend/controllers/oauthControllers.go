@@ -1,19 +1,26 @@
 package controllers
 
 import (
+	"deltra-backend/auth"
 	"deltra-backend/config"
 	"deltra-backend/models"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// Identity comes only from the verified ID token. Name and Picture are
+// display hints for Apple, which leaves them out of the token.
 type OAuthRequest struct {
-	ProviderID string `json:"providerId" binding:"required"`
-	Provider   string `json:"provider" binding:"required"`
-	Email      string `json:"email" binding:"required"`
-	Name       string `json:"name" binding:"required"`
-	Picture    string `json:"picture"`
+	Provider string `json:"provider" binding:"required"`
+	IDToken  string `json:"idToken" binding:"required"`
+	Nonce    string `json:"nonce" binding:"required"`
+	Name     string `json:"name"`
+	Picture  string `json:"picture"`
 }
 
 type OAuthResponse struct {
@@ -22,6 +29,8 @@ type OAuthResponse struct {
 	Name      string `json:"name"`
 	Picture   string `json:"picture"`
 	IsNewUser bool   `json:"isNewUser"`
+
+	auth.TokenPair
 }
 
 func CreateOrFindOAuthUser(c *gin.Context) {
@@ -36,35 +45,43 @@ func CreateOrFindOAuthUser(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	var isNewUser bool
+	identity, err := auth.VerifyIDToken(c.Request.Context(), req.Provider, req.IDToken, req.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ID token"})
+		return
+	}
 
-	result := config.DB.Where("provider_id = ? AND provider = ?", req.ProviderID, req.Provider).First(&user)
+	name := identity.Name
+	if name == "" {
+		name = req.Name
+	}
+	picture := identity.Picture
+	if picture == "" {
+		picture = req.Picture
+	}
 
-	if result.Error != nil {
-		user = models.User{
-			Name:       req.Name,
-			Email:      req.Email,
-			Provider:   req.Provider,
-			ProviderID: req.ProviderID,
-			Picture:    req.Picture,
-		}
+	user, isNewUser, err := signInIdentity(identity, name, picture)
+	if errors.Is(err, errEmailInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "An account with this email already exists. Sign in with your original provider and link this one from settings."})
+		return
+	}
+	if errors.Is(err, errMissingEmail) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID token has no email claim"})
+		return
+	}
+	if errors.Is(err, errEmailUnverified) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your provider has not verified this email address"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in user"})
+		return
+	}
 
-		if err := config.DB.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-			return
-		}
-		isNewUser = true
-	} else {
-		user.Name = req.Name
-		user.Email = req.Email
-		user.Picture = req.Picture
-
-		if err := config.DB.Save(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-			return
-		}
-		isNewUser = false
+	tokens, err := auth.StartSession(user, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
 	}
 
 	response := OAuthResponse{
@@ -73,8 +90,88 @@ func CreateOrFindOAuthUser(c *gin.Context) {
 		Name:      user.Name,
 		Picture:   user.Picture,
 		IsNewUser: isNewUser,
+		TokenPair: tokens,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+var (
+	errEmailInUse      = errors.New("email belongs to another account")
+	errMissingEmail    = errors.New("identity has no email")
+	errEmailUnverified = errors.New("identity email is not verified")
+)
+
+// signInIdentity finds the user linked to a provider identity. Unknown
+// identities are linked to an existing account only when the provider has
+// verified the email; otherwise a new user is created. Accounts are only
+// created from verified emails, since invitations are matched on them.
+func signInIdentity(identity auth.Identity, name, picture string) (models.User, bool, error) {
+	var user models.User
+	isNewUser := false
+	now := time.Now()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var linked models.Identity
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error
+		switch {
+		case err == nil:
+			if err := tx.Where("id = ?", linked.UserID).First(&user).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if identity.Email == "" {
+				return errMissingEmail
+			}
+
+			err := tx.Where("email = ?", identity.Email).First(&user).Error
+			switch {
+			case err == nil:
+				if !identity.EmailVerified {
+					return errEmailInUse
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if !identity.EmailVerified {
+					return errEmailUnverified
+				}
+				user = models.User{Name: name, Email: identity.Email, EmailVerified: true, Picture: picture}
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+				isNewUser = true
+			default:
+				return err
+			}
+
+			linked = models.Identity{
+				UserID:   user.ID,
+				Provider: identity.Provider,
+				Subject:  identity.Subject,
+			}
+		default:
+			return err
+		}
+
+		linked.Email = identity.Email
+		linked.LastUsedAt = now
+		if err := tx.Save(&linked).Error; err != nil {
+			return err
+		}
+
+		if isNewUser {
+			return nil
+		}
+		if name != "" {
+			user.Name = name
+		}
+		if picture != "" {
+			user.Picture = picture
+		}
+		if identity.EmailVerified && strings.EqualFold(identity.Email, user.Email) {
+			user.EmailVerified = true
+		}
+		return tx.Save(&user).Error
+	})
+
+	return user, isNewUser, err
+}
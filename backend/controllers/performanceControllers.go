@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/performance"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	dateLayout    = "2006-01-02"
+	maxRangeYears = 10
+)
+
+type PerformanceResponse struct {
+	PortfolioID     string              `json:"portfolio_id"`
+	From            time.Time           `json:"from"`
+	To              time.Time           `json:"to"`
+	StartValue      float64             `json:"start_value"`
+	EndValue        float64             `json:"end_value"`
+	NetFlows        float64             `json:"net_flows"`
+	TWR             float64             `json:"twr"`
+	AnnualizedTWR   float64             `json:"annualized_twr"`
+	IRR             *float64            `json:"irr"`
+	Benchmark       string              `json:"benchmark,omitempty"`
+	BenchmarkReturn *float64            `json:"benchmark_return,omitempty"`
+	MarketPriced    bool                `json:"market_priced"`
+	Series          []performance.Point `json:"series"`
+}
+
+func parseDateRange(c *gin.Context, start time.Time) (time.Time, time.Time, bool) {
+	to := performance.Day(time.Now())
+	from := performance.Day(start)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return from, to, false
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return from, to, false
+		}
+		to = parsed
+	}
+
+	return clampDateRange(c, from, to, start)
+}
+
+// clampDateRange keeps a range to days that can have history: from the
+// start of the portfolio or stock through today, and no longer than
+// maxRangeYears, since every day in it gets valued or stored.
+func clampDateRange(c *gin.Context, from, to, start time.Time) (time.Time, time.Time, bool) {
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return from, to, false
+	}
+
+	if start = performance.Day(start); from.Before(start) {
+		from = start
+	}
+	if today := performance.Day(time.Now()); to.After(today) {
+		to = today
+	}
+	if from.After(to) {
+		from = to
+	}
+
+	if to.After(from.AddDate(maxRangeYears, 0, 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("date range must not exceed %d years", maxRangeYears)})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// portfolioStart is the first day a portfolio has history: when it was
+// created, or an earlier back-dated transaction.
+func portfolioStart(db *gorm.DB, portfolio models.Portfolio) time.Time {
+	start := portfolio.CreatedAt
+	var first sql.NullTime
+	db.Model(&models.CashTransaction{}).
+		Where("portfolio_id = ?", portfolio.ID).
+		Select("MIN(occurred_at)").
+		Scan(&first)
+	if first.Valid && first.Time.Before(start) {
+		start = first.Time
+	}
+	return performance.Day(start)
+}
+
+func priceHistories(ctx context.Context, stocks []models.Stock, from, to time.Time) (map[string][]marketdata.Bar, bool) {
+	histories := make(map[string][]marketdata.Bar)
+	complete := marketdata.Configured()
+
+	for _, stock := range stocks {
+		symbol := strings.ToUpper(stock.Symbol)
+		if _, ok := histories[symbol]; ok {
+			continue
+		}
+		bars, err := marketdata.GetHistory(ctx, symbol, from.AddDate(0, 0, -7), to)
+		if err != nil {
+			complete = false
+		}
+		histories[symbol] = bars
+	}
+
+	return histories, complete
+}
+
+// buildDailyValuations values the portfolio on each day from its ledger,
+// priced at each day's close. Days that already have a snapshot use it, so
+// the report matches what was recorded at the time.
+func buildDailyValuations(ctx context.Context, ledger performance.Ledger, snapshots []models.PortfolioSnapshot, days []time.Time) ([]performance.Valuation, bool) {
+	recorded := make(map[time.Time]float64, len(snapshots))
+	for _, snapshot := range snapshots {
+		recorded[performance.Day(snapshot.Date)] = snapshot.TotalValue
+	}
+
+	var missing []time.Time
+	for _, day := range days {
+		if _, ok := recorded[day]; !ok {
+			missing = append(missing, day)
+		}
+	}
+
+	complete := true
+	var histories map[string][]marketdata.Bar
+	if len(missing) > 0 {
+		histories, complete = priceHistories(ctx, ledger.Stocks, missing[0], missing[len(missing)-1])
+	}
+	prices := func(symbol string, day time.Time) (float64, bool) {
+		return marketdata.CloseOn(histories[strings.ToUpper(symbol)], day)
+	}
+
+	valuations := make([]performance.Valuation, 0, len(days))
+	for _, day := range days {
+		value, ok := recorded[day]
+		if !ok {
+			value = ledger.ValueOn(day, prices).TotalValue
+		}
+		valuations = append(valuations, performance.Valuation{Date: day, Value: value})
+	}
+
+	return valuations, complete
+}
+
+func GetPortfolioPerformance(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).
+		First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	from, to, ok := parseDateRange(c, portfolioStart(dbFor(c), portfolio))
+	if !ok {
+		return
+	}
+
+	ledger, err := performance.LoadLedger(dbFor(c), portfolio.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch portfolio history"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	var snapshots []models.PortfolioSnapshot
+	dbFor(c).Where("portfolio_id = ? AND date >= ? AND date <= ?", portfolio.ID, from, to).
+		Order("date ASC").
+		Find(&snapshots)
+
+	// A range with no trading day, like a portfolio opened on a weekend,
+	// is valued on its last day alone.
+	days := performance.TradingDays(from, to)
+	if len(days) == 0 {
+		days = []time.Time{to}
+	}
+	valuations, complete := buildDailyValuations(ctx, ledger, snapshots, days)
+
+	flows := ledger.ExternalFlows()
+	series := performance.TimeWeightedSeries(valuations, flows)
+	start, end := valuations[0], valuations[len(valuations)-1]
+
+	response := PerformanceResponse{
+		PortfolioID:  portfolio.ID,
+		From:         start.Date,
+		To:           end.Date,
+		StartValue:   start.Value,
+		EndValue:     end.Value,
+		TWR:          series[len(series)-1].Return,
+		MarketPriced: complete,
+		Series:       series,
+	}
+	response.AnnualizedTWR = performance.Annualize(response.TWR, start.Date, end.Date)
+
+	for _, flow := range flows {
+		if flow.Date.After(start.Date) && !flow.Date.After(end.Date) {
+			response.NetFlows += flow.Amount
+		}
+	}
+
+	if irr, err := performance.MoneyWeightedReturn(start, end, flows); err == nil {
+		response.IRR = &irr
+	}
+
+	benchmark := strings.ToUpper(c.DefaultQuery("benchmark", "SPY"))
+	if bars, err := marketdata.GetHistory(ctx, benchmark, from.AddDate(0, 0, -7), to); err == nil && len(bars) > 0 {
+		if base, ok := marketdata.CloseOn(bars, start.Date); ok && base > 0 {
+			response.Benchmark = benchmark
+			for i := range response.Series {
+				if price, ok := marketdata.CloseOn(bars, response.Series[i].Date); ok {
+					benchmarkReturn := price/base - 1
+					response.Series[i].BenchmarkReturn = &benchmarkReturn
+				}
+			}
+			response.BenchmarkReturn = response.Series[len(response.Series)-1].BenchmarkReturn
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
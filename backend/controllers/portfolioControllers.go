@@ -1,22 +1,44 @@
 package controllers
 
 import (
-	"deltra-backend/config"
+	"deltra-backend/audit"
 	"deltra-backend/models"
+	"deltra-backend/query"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// Only these fields can be set on create; members, stocks and the rest are
+// managed through their own routes.
+type CreatePortfolioRequest struct {
+	Name        string `json:"name"`
+	EnforceCash bool   `json:"enforce_cash"`
+}
+
+var portfolioList = query.Spec{
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+	DefaultSort: "created_at",
+	Filters: []query.Filter{
+		query.DateRange("created", "created_at"),
+	},
+}
+
 func GetPortfolios(c *gin.Context) {
 	userID := c.Param("id")
 	var portfolios []models.Portfolio
-	config.DB.Where("user_id = ?", userID).
-		Preload("User").
-		Preload("Stocks.CoveredCalls").
-		Find(&portfolios)
+	if !findList(c, portfolioList, dbFor(c).Where("id IN (?)", memberPortfolioIDs(userID)),
+		&portfolios, "Failed to fetch portfolios", "User", "Stocks.CoveredCalls", "Stocks.Dividends") {
+		return
+	}
 
 	for i := range portfolios {
+		portfolios[i].CashBalance, _ = cashBalance(dbFor(c), portfolios[i].ID)
+		portfolios[i].Role = portfolioRole(userID, portfolios[i].ID)
 		for j := range portfolios[i].Stocks {
 			portfolios[i].Stocks[j].CalculateMetrics()
 		}
@@ -29,23 +51,36 @@ func AddPortfolio(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := dbFor(c).Where("id = ?", userID).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	var portfolio models.Portfolio
-	if err := c.ShouldBindJSON(&portfolio); err != nil {
+	var req CreatePortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	portfolio.UserID = userID
+	portfolio := models.Portfolio{
+		Name:        req.Name,
+		EnforceCash: req.EnforceCash,
+		UserID:      userID,
+	}
 
-	if err := config.DB.Create(&portfolio).Error; err != nil {
+	if err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&portfolio).Error; err != nil {
+			return err
+		}
+		if err := addPortfolioOwner(tx, portfolio, user); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditCreate, &portfolio, nil)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create portfolio"})
 		return
 	}
+	portfolio.Role = models.RoleOwner
 
 	c.JSON(http.StatusCreated, portfolio)
 }
@@ -55,22 +90,38 @@ func UpdatePortfolio(c *gin.Context) {
 	portfolioID := c.Param("portfolioId")
 
 	var portfolio models.Portfolio
-	if err := config.DB.Where("id = ? AND user_id = ?", portfolioID, userID).First(&portfolio).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
 
+	if !requirePortfolioRole(c, userID, portfolio.ID, models.RoleOwner) {
+		return
+	}
+
 	var updateData struct {
-		Name string `json:"name" binding:"required"`
+		Name        string `json:"name"`
+		EnforceCash *bool  `json:"enforce_cash"`
 	}
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	portfolio.Name = updateData.Name
+	if updateData.Name == "" && updateData.EnforceCash == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name or enforce_cash is required"})
+		return
+	}
+
+	before := audit.State(&portfolio)
+	if updateData.Name != "" {
+		portfolio.Name = updateData.Name
+	}
+	if updateData.EnforceCash != nil {
+		portfolio.EnforceCash = *updateData.EnforceCash
+	}
 
-	if err := config.DB.Save(&portfolio).Error; err != nil {
+	if err := saveAudited(c, &portfolio, before); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update portfolio"})
 		return
 	}
@@ -83,12 +134,16 @@ func DeletePortfolio(c *gin.Context) {
 	portfolioID := c.Param("portfolioId")
 
 	var portfolio models.Portfolio
-	if err := config.DB.Where("id = ? AND user_id = ?", portfolioID, userID).First(&portfolio).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
 		return
 	}
 
-	if err := config.DB.Delete(&portfolio).Error; err != nil {
+	if !requirePortfolioRole(c, userID, portfolio.ID, models.RoleOwner) {
+		return
+	}
+
+	if err := deleteAudited(c, &portfolio); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete portfolio"})
 		return
 	}
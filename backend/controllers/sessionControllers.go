@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"deltra-backend/auth"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func RefreshSession(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := auth.Refresh(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	switch {
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		return
+	case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, auth.ErrSessionRevoked):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token, please sign in again"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func Logout(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := auth.RevokeSessionByRefreshToken(req.RefreshToken, "logout"); err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+func GetSessions(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var sessions []models.Session
+	if err := dbFor(c).Where("user_id = ? AND revoked_at IS NULL AND expires_at > NOW()", principal.UserID).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == principal.SessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func RevokeSession(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+	sessionID := c.Param("sessionId")
+
+	err := auth.RevokeSession(principal.UserID, sessionID, "revoked_by_user")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func RevokeOtherSessions(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	revoked, err := auth.RevokeOtherSessions(principal.UserID, principal.SessionID, "revoked_by_user")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
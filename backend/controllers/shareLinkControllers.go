@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"deltra-backend/auth"
+	"deltra-backend/models"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateShareLinkRequest struct {
+	HideAmounts *bool      `json:"hide_amounts,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type CreateShareLinkResponse struct {
+	models.ShareLink
+	Token string `json:"token"`
+}
+
+// SharedPortfolio is the public view behind a share link. Pointer fields are
+// left out when the owner hides amounts.
+type SharedPortfolio struct {
+	Name         string           `json:"name"`
+	HideAmounts  bool             `json:"hide_amounts"`
+	PremiumYield float64          `json:"premium_yield"`
+	Positions    []SharedPosition `json:"positions"`
+	CostBasis    *float64         `json:"cost_basis,omitempty"`
+	NetPremium   *float64         `json:"net_premium,omitempty"`
+}
+
+type SharedPosition struct {
+	Symbol        string   `json:"symbol"`
+	Weight        float64  `json:"weight"`
+	PremiumYield  float64  `json:"premium_yield"`
+	ActiveCalls   int      `json:"active_calls"`
+	Shares        *float64 `json:"shares,omitempty"`
+	Basis         *float64 `json:"basis,omitempty"`
+	AdjustedBasis *float64 `json:"adjusted_basis,omitempty"`
+	NetPremium    *float64 `json:"net_premium,omitempty"`
+}
+
+func GetShareLinks(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	if !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+
+	var links []models.ShareLink
+	if err := dbFor(c).Where("portfolio_id = ? AND revoked_at IS NULL", portfolioID).
+		Order("created_at DESC").
+		Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+func CreateShareLink(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	if !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	raw, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	link := models.ShareLink{
+		PortfolioID: portfolioID,
+		UserID:      userID,
+		TokenHash:   hash,
+		HideAmounts: true,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if req.HideAmounts != nil {
+		link.HideAmounts = *req.HideAmounts
+	}
+
+	if err := dbFor(c).Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateShareLinkResponse{ShareLink: link, Token: raw})
+}
+
+func RevokeShareLink(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+	linkID := c.Param("linkId")
+
+	if !requirePortfolioRole(c, userID, portfolioID, models.RoleOwner) {
+		return
+	}
+
+	var link models.ShareLink
+	if err := dbFor(c).Where("id = ? AND portfolio_id = ?", linkID, portfolioID).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		link.RevokedAt = &now
+		if err := dbFor(c).Save(&link).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// GetSharedPortfolio serves a share link without authentication. Unknown,
+// revoked and expired tokens all return the same 404.
+func GetSharedPortfolio(c *gin.Context) {
+	token := c.Param("token")
+	now := time.Now()
+
+	var link models.ShareLink
+	if err := dbFor(c).Where("token_hash = ?", auth.HashToken(token)).First(&link).Error; err != nil || !link.Active(now) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ?", link.PortfolioID).
+		Preload("Stocks.CoveredCalls").
+		Preload("Stocks.Dividends").
+		First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	dbFor(c).Model(&link).Updates(map[string]any{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": now,
+	})
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, sharedPortfolioView(portfolio, link.HideAmounts))
+}
+
+func sharedPortfolioView(portfolio models.Portfolio, hideAmounts bool) SharedPortfolio {
+	view := SharedPortfolio{
+		Name:        portfolio.Name,
+		HideAmounts: hideAmounts,
+		Positions:   []SharedPosition{},
+	}
+
+	costBasis := 0.0
+	netPremium := 0.0
+	for i := range portfolio.Stocks {
+		portfolio.Stocks[i].CalculateMetrics()
+		costBasis += portfolio.Stocks[i].Basis * portfolio.Stocks[i].Shares
+		netPremium += portfolio.Stocks[i].NetPremium
+	}
+
+	for _, stock := range portfolio.Stocks {
+		cost := stock.Basis * stock.Shares
+		position := SharedPosition{
+			Symbol:      stock.Symbol,
+			ActiveCalls: stock.ActiveCalls,
+		}
+		if costBasis > 0 {
+			position.Weight = cost / costBasis * 100
+		}
+		if cost > 0 {
+			position.PremiumYield = stock.NetPremium / cost * 100
+		}
+		if !hideAmounts {
+			position.Shares = &stock.Shares
+			position.Basis = &stock.Basis
+			position.AdjustedBasis = &stock.AdjustedBasis
+			position.NetPremium = &stock.NetPremium
+		}
+		view.Positions = append(view.Positions, position)
+	}
+
+	if costBasis > 0 {
+		view.PremiumYield = netPremium / costBasis * 100
+	}
+	if !hideAmounts {
+		view.CostBasis = &costBasis
+		view.NetPremium = &netPremium
+	}
+
+	return view
+}
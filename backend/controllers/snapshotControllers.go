@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"deltra-backend/jobs"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/performance"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Backfilling leaves snapshots recorded at the time alone unless Overwrite
+// is set, so reports keep matching what was recorded.
+type BackfillSnapshotsRequest struct {
+	From      string `json:"from" binding:"required"`
+	To        string `json:"to"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+func GetEquityCurve(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	from, to, ok := parseDateRange(c, portfolioStart(dbFor(c), portfolio))
+	if !ok {
+		return
+	}
+
+	var snapshots []models.PortfolioSnapshot
+	if err := dbFor(c).Where("portfolio_id = ? AND date >= ? AND date <= ?", portfolio.ID, from, to).
+		Order("date ASC").
+		Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+func GetStockSnapshots(c *gin.Context) {
+	userID := c.Param("id")
+	stockID := c.Param("stockId")
+
+	var stock models.Stock
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
+		return
+	}
+
+	from, to, ok := parseDateRange(c, stock.CreatedAt)
+	if !ok {
+		return
+	}
+
+	var snapshots []models.StockSnapshot
+	if err := dbFor(c).Where("stock_id = ? AND date >= ? AND date <= ?", stock.ID, from, to).
+		Order("date ASC").
+		Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+func BackfillSnapshots(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+	var req BackfillSnapshotsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !marketdata.Configured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Market data provider not configured"})
+		return
+	}
+
+	from, err := time.Parse(dateLayout, req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+		return
+	}
+	to := performance.Day(time.Now())
+	if req.To != "" {
+		if to, err = time.Parse(dateLayout, req.To); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", portfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, portfolio.ID, editorRoles...) {
+		return
+	}
+
+	from, to, ok := clampDateRange(c, from, to, portfolioStart(dbFor(c), portfolio))
+	if !ok {
+		return
+	}
+
+	days, err := jobs.Backfill(c.Request.Context(), portfolio, from, to, req.Overwrite)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to backfill snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"days": days})
+}
@@ -1,29 +1,96 @@
 package controllers
 
 import (
-	"deltra-backend/config"
+	"deltra-backend/audit"
 	"deltra-backend/models"
+	"deltra-backend/query"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// Only these fields can be set on create; calls and dividends are recorded
+// through their own routes. PurchaseFees defaults to the portfolio's fee
+// schedule when left out.
+type CreateStockRequest struct {
+	PortfolioID          string   `json:"portfolio_id"`
+	Symbol               string   `json:"symbol"`
+	Basis                float64  `json:"basis"`
+	Shares               float64  `json:"shares"`
+	PurchaseFees         *float64 `json:"purchase_fees"`
+	DividendsReduceBasis bool     `json:"dividends_reduce_basis"`
+}
+
+var stockList = query.Spec{
+	Sorts: map[string]string{
+		"symbol":     "symbol",
+		"shares":     "shares",
+		"basis":      "basis",
+		"created_at": "created_at",
+	},
+	DefaultSort: "-created_at",
+	Filters: []query.Filter{
+		query.Custom("symbol", func(db *gorm.DB, symbols []string) *gorm.DB {
+			return db.Where("UPPER(symbol) IN ?", upperAll(symbols))
+		}),
+		query.Equals("portfolio_id", "portfolio_id"),
+		query.DateRange("created", "created_at"),
+	},
+}
+
 func GetStocks(c *gin.Context) {
 	userID := c.Param("id")
 
 	var stocks []models.Stock
-	config.DB.Where("user_id = ?", userID).Preload("User").Find(&stocks)
+	if !findList(c, stockList, dbFor(c).Where("portfolio_id IN (?)", memberPortfolioIDs(userID)), &stocks, "Failed to fetch stocks", "User") {
+		return
+	}
+
 	c.JSON(http.StatusOK, stocks)
 }
 
+// findList runs a list query with the request's pagination, filter and sort
+// parameters and writes the error response when it fails.
+func findList(c *gin.Context, spec query.Spec, db *gorm.DB, dest any, message string, preloads ...string) bool {
+	list, err := query.Parse(c, spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	err = list.Find(c, db, dest, preloads...)
+	var invalid *query.Error
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return false
+	}
+	return true
+}
+
+func upperAll(values []string) []string {
+	upper := make([]string, len(values))
+	for i, value := range values {
+		upper[i] = strings.ToUpper(value)
+	}
+	return upper
+}
+
 func GetStock(c *gin.Context) {
 	userID := c.Param("id")
 	stockID := c.Param("stockId")
 
 	var stock models.Stock
-	if err := config.DB.Where("id = ? AND user_id = ?", stockID, userID).
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).
 		Preload("CoveredCalls").
+		Preload("Dividends").
 		Preload("Portfolio").
 		Preload("User").
 		First(&stock).Error; err != nil {
@@ -40,28 +107,65 @@ func AddStock(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.User
-	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := dbFor(c).Where("id = ?", userID).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	var stock models.Stock
-	if err := c.ShouldBindJSON(&stock); err != nil {
+	var req CreateStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	stock.UserID = userID
+	stock := models.Stock{
+		PortfolioID:          req.PortfolioID,
+		Symbol:               req.Symbol,
+		Basis:                req.Basis,
+		Shares:               req.Shares,
+		DividendsReduceBasis: req.DividendsReduceBasis,
+	}
 
-	if stock.PortfolioID != "" {
-		var portfolio models.Portfolio
-		if err := config.DB.Where("id = ? AND user_id = ?", stock.PortfolioID, userID).First(&portfolio).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Portfolio not found or doesn't belong to user"})
-			return
-		}
+	if stock.PortfolioID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "portfolio_id is required"})
+		return
+	}
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Where("id = ? AND id IN (?)", stock.PortfolioID, memberPortfolioIDs(userID)).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Portfolio not found or doesn't belong to user"})
+		return
+	}
+	if !requirePortfolioRole(c, userID, portfolio.ID, editorRoles...) {
+		return
+	}
+
+	// Stocks in a shared portfolio belong to the portfolio's owner.
+	stock.UserID = portfolio.UserID
+
+	if req.PurchaseFees == nil {
+		stock.PurchaseFees = portfolioFeeSchedule(stock.PortfolioID).StockTrade(stock.Shares)
+	} else if *req.PurchaseFees < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return
+	} else {
+		stock.PurchaseFees = *req.PurchaseFees
 	}
 
-	if err := config.DB.Create(&stock).Error; err != nil {
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&stock).Error; err != nil {
+			return err
+		}
+		if err := recordStockPurchase(tx, stock); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditCreate, &stock, nil)
+	})
+	if errors.Is(err, errInsufficientCash) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient cash for purchase"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock"})
 		return
 	}
@@ -74,20 +178,27 @@ func UpdateStock(c *gin.Context) {
 	stockID := c.Param("stockId")
 
 	var stock models.Stock
-	if err := config.DB.Where("id = ? AND user_id = ?", stockID, userID).First(&stock).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
 		return
 	}
 
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
 	var updateData map[string]any
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	before := audit.State(&stock)
+
 	fields := map[string]*float64{
-		"shares": &stock.Shares,
-		"basis":  &stock.Basis,
+		"shares":        &stock.Shares,
+		"basis":         &stock.Basis,
+		"purchase_fees": &stock.PurchaseFees,
 	}
 
 	for fieldName, stockField := range fields {
@@ -103,7 +214,21 @@ func UpdateStock(c *gin.Context) {
 		}
 	}
 
-	if err := config.DB.Save(&stock).Error; err != nil {
+	if value, ok := updateData["dividends_reduce_basis"]; ok {
+		boolValue, ok := value.(bool)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Field 'dividends_reduce_basis' must be a boolean"})
+			return
+		}
+		stock.DividendsReduceBasis = boolValue
+	}
+
+	if stock.PurchaseFees < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fees cannot be negative"})
+		return
+	}
+
+	if err := saveAudited(c, &stock, before); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
 		return
 	}
@@ -116,12 +241,16 @@ func DeleteStock(c *gin.Context) {
 	stockID := c.Param("stockId")
 
 	var stock models.Stock
-	if err := config.DB.Where("id = ? AND user_id = ?", stockID, userID).First(&stock).Error; err != nil {
+	if err := dbFor(c).Where("id = ? AND portfolio_id IN (?)", stockID, memberPortfolioIDs(userID)).First(&stock).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Stock not found"})
 		return
 	}
 
-	if err := config.DB.Delete(&stock).Error; err != nil {
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
+	if err := deleteAudited(c, &stock); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stock"})
 		return
 	}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/events"
+	"deltra-backend/marketdata"
+	"deltra-backend/middleware"
+	"deltra-backend/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeat    = 25 * time.Second
+	streamQuoteTick    = 30 * time.Second
+	streamRetryMs      = 5000
+	streamQuoteEvent   = "quote"
+	streamConnectEvent = "ready"
+)
+
+// StreamEvents pushes changes to the user's portfolios, stocks and calls as
+// server-sent events, plus quote ticks for their symbols when market data is
+// configured. Clients should refetch after reconnecting, since changes made
+// while disconnected are not replayed. The stream closes when the caller's
+// token expires or its session or API key is revoked, so clients reconnect
+// with fresh credentials.
+func StreamEvents(c *gin.Context) {
+	userID := c.Param("id")
+	principal, _ := middleware.AuthPrincipal(c)
+
+	changes, cancel := events.Subscribe(userID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMs)
+	writeStreamEvent(w, "", streamConnectEvent, gin.H{"user_id": userID})
+	w.Flush()
+
+	var quotes <-chan marketdata.Quote
+	if marketdata.Configured() {
+		quotes = streamQuotes(c.Request.Context(), userID)
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	var expired <-chan time.Time
+	if !principal.ExpiresAt.IsZero() {
+		expiry := time.NewTimer(time.Until(principal.ExpiresAt))
+		defer expiry.Stop()
+		expired = expiry.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			return
+		case event, ok := <-changes:
+			if !ok {
+				// Dropped for falling behind; the client reconnects.
+				return
+			}
+			writeStreamEvent(w, event.ID, event.Type, event)
+		case quote := <-quotes:
+			writeStreamEvent(w, "", streamQuoteEvent, quote)
+		case <-heartbeat.C:
+			if !principal.Active(time.Now()) {
+				return
+			}
+			io.WriteString(w, ": ping\n\n")
+		}
+		w.Flush()
+	}
+}
+
+func writeStreamEvent(w io.Writer, id, name string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, payload)
+}
+
+// streamQuotes polls quotes for the symbols the user holds and sends the
+// ones whose price moved. Symbols are reloaded each tick so new positions
+// are picked up.
+func streamQuotes(ctx context.Context, userID string) <-chan marketdata.Quote {
+	out := make(chan marketdata.Quote)
+
+	go func() {
+		ticker := time.NewTicker(streamQuoteTick)
+		defer ticker.Stop()
+
+		last := map[string]float64{}
+		for {
+			var symbols []string
+			config.DB.Model(&models.Stock{}).
+				Where("portfolio_id IN (?)", memberPortfolioIDs(userID)).
+				Distinct().
+				Pluck("symbol", &symbols)
+
+			for _, symbol := range symbols {
+				quote, err := marketdata.GetQuote(ctx, symbol)
+				if err != nil || quote.Price == last[symbol] {
+					continue
+				}
+				last[symbol] = quote.Price
+
+				select {
+				case out <- quote:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return out
+}
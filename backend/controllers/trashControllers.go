@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"deltra-backend/jobs"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errParentDeleted = errors.New("parent is deleted")
+
+type Trash struct {
+	Portfolios    []models.Portfolio   `json:"portfolios"`
+	Stocks        []models.Stock       `json:"stocks"`
+	CoveredCalls  []models.CoveredCall `json:"covered_calls"`
+	RetentionDays int                  `json:"retention_days"`
+}
+
+// softDelete moves an entity and everything under it to the trash with a
+// single timestamp, so restoring brings back exactly what this delete
+// removed and not children that were deleted earlier on their own.
+func softDelete(tx *gorm.DB, entity any) error {
+	now := time.Now().Truncate(time.Microsecond)
+
+	switch e := entity.(type) {
+	case *models.Portfolio:
+		if err := tx.Model(&models.CoveredCall{}).Where("portfolio_id = ?", e.ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Stock{}).Where("portfolio_id = ?", e.ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		e.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	case *models.Stock:
+		if err := tx.Model(&models.CoveredCall{}).Where("stock_id = ?", e.ID).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		e.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	case *models.CoveredCall:
+		e.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	}
+
+	return tx.Model(entity).Update("deleted_at", now).Error
+}
+
+func isTrashed(entity any) bool {
+	switch e := entity.(type) {
+	case *models.Portfolio:
+		return e.DeletedAt.Valid
+	case *models.Stock:
+		return e.DeletedAt.Valid
+	case *models.CoveredCall:
+		return e.DeletedAt.Valid
+	}
+	return false
+}
+
+// restoreDeleted undoes softDelete. Stocks and calls can only come back
+// while their portfolio and stock are still live.
+func restoreDeleted(tx *gorm.DB, entity any) error {
+	var deletedAt gorm.DeletedAt
+
+	switch e := entity.(type) {
+	case *models.Portfolio:
+		deletedAt = e.DeletedAt
+		if err := tx.Unscoped().Model(&models.Stock{}).
+			Where("portfolio_id = ? AND deleted_at = ?", e.ID, deletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.CoveredCall{}).
+			Where("portfolio_id = ? AND deleted_at = ?", e.ID, deletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	case *models.Stock:
+		deletedAt = e.DeletedAt
+		if err := tx.Where("id = ?", e.PortfolioID).First(&models.Portfolio{}).Error; err != nil {
+			return errParentDeleted
+		}
+		if err := tx.Unscoped().Model(&models.CoveredCall{}).
+			Where("stock_id = ? AND deleted_at = ?", e.ID, deletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	case *models.CoveredCall:
+		if err := tx.Where("id = ?", e.StockID).First(&models.Stock{}).Error; err != nil {
+			return errParentDeleted
+		}
+	}
+
+	return tx.Unscoped().Model(entity).Update("deleted_at", nil).Error
+}
+
+func GetTrash(c *gin.Context) {
+	userID := c.Param("id")
+	editable := memberPortfolioIDs(userID, editorRoles...)
+
+	trash := Trash{RetentionDays: int(jobs.TrashRetention().Hours() / 24)}
+
+	// A deleted portfolio's membership rows survive, but only owners see it.
+	if err := dbFor(c).Unscoped().
+		Where("deleted_at IS NOT NULL AND id IN (?)", dbFor(c).Model(&models.PortfolioMember{}).
+			Select("portfolio_id").
+			Where("user_id = ? AND role = ?", userID, models.RoleOwner)).
+		Order("deleted_at DESC").
+		Find(&trash.Portfolios).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+		return
+	}
+
+	// Children deleted along with a portfolio or stock are restored with it,
+	// so only list items deleted on their own.
+	if err := dbFor(c).Unscoped().
+		Where("stocks.deleted_at IS NOT NULL AND stocks.portfolio_id IN (?)", editable).
+		Joins("JOIN portfolios ON portfolios.id = stocks.portfolio_id AND portfolios.deleted_at IS NULL").
+		Order("stocks.deleted_at DESC").
+		Find(&trash.Stocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+		return
+	}
+
+	if err := dbFor(c).Unscoped().
+		Where("covered_calls.deleted_at IS NOT NULL AND covered_calls.portfolio_id IN (?)", editable).
+		Joins("JOIN stocks ON stocks.id = covered_calls.stock_id AND stocks.deleted_at IS NULL").
+		Order("covered_calls.deleted_at DESC").
+		Find(&trash.CoveredCalls).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+func RestorePortfolio(c *gin.Context) {
+	userID := c.Param("id")
+	portfolioID := c.Param("portfolioId")
+
+	var portfolio models.Portfolio
+	if err := dbFor(c).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", portfolioID).First(&portfolio).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted portfolio not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, portfolio.ID, models.RoleOwner) {
+		return
+	}
+
+	restoreFromTrash(c, &portfolio)
+}
+
+func RestoreStock(c *gin.Context) {
+	userID := c.Param("id")
+	stockID := c.Param("stockId")
+
+	var stock models.Stock
+	if err := dbFor(c).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", stockID).First(&stock).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted stock not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, stock.PortfolioID, editorRoles...) {
+		return
+	}
+
+	restoreFromTrash(c, &stock)
+}
+
+func RestoreCoveredCall(c *gin.Context) {
+	userID := c.Param("id")
+	callID := c.Param("callId")
+
+	var coveredCall models.CoveredCall
+	if err := dbFor(c).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", callID).First(&coveredCall).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted covered call not found"})
+		return
+	}
+
+	if !requirePortfolioRole(c, userID, coveredCall.PortfolioID, editorRoles...) {
+		return
+	}
+
+	restoreFromTrash(c, &coveredCall)
+}
+
+func restoreFromTrash(c *gin.Context, entity any) {
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := restoreDeleted(tx, entity); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, models.AuditRevert, entity, nil)
+	})
+	if errors.Is(err, errParentDeleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Restore the portfolio or stock this belongs to first"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entity)
+}
@@ -1,31 +1,63 @@
 package controllers
 
 import (
-	"deltra-backend/config"
+	"deltra-backend/auth"
+	"deltra-backend/middleware"
 	"deltra-backend/models"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func GetProfile(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
+const accountDeletionPurpose = "delete_account"
 
-	c.JSON(http.StatusOK, gin.H{
-		"user_id": userID,
-		"message": "Profile endpoint - implement user lookup",
-	})
+type UpdateMeRequest struct {
+	Name        *string                 `json:"name,omitempty"`
+	Picture     *string                 `json:"picture,omitempty"`
+	Preferences *models.UserPreferences `json:"preferences,omitempty"`
+}
+
+type DeleteMeRequest struct {
+	ConfirmationToken string `json:"confirmation_token" binding:"required"`
+}
+
+// accountDeletionOrder lists user data children-first so foreign keys hold
+// at every step of the delete.
+var accountDeletionOrder = []any{
+	&models.StockSnapshot{},
+	&models.PortfolioSnapshot{},
+	&models.CashTransaction{},
+	&models.Dividend{},
+	&models.CoveredCall{},
+	&models.CorporateAction{},
+	&models.Stock{},
+	&models.ShareLink{},
+	&models.FeeSchedule{},
+	&models.AuditEvent{},
+	&models.Portfolio{},
+	&models.APIKey{},
+	&models.Identity{},
+	&models.CalendarFeed{},
+	&models.AlertEvent{},
+	&models.AlertRule{},
+	&models.NotificationDelivery{},
+	&models.Notification{},
+	&models.PushDevice{},
+	&models.NotificationWebhook{},
+	&models.WebhookDelivery{},
+	&models.WebhookEndpoint{},
+	&models.Session{},
 }
 
 func GetUser(c *gin.Context) {
 	var user models.User
 	id := c.Param("id")
 
-	if err := config.DB.Where("id = ?", id).First(&user).Error; err != nil {
+	if err := dbFor(c).Where("id = ?", id).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
@@ -33,14 +65,181 @@ func GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func AddUser(c *gin.Context) {
+func GetMe(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
 	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	if err := dbFor(c).Where("id = ?", principal.UserID).
+		Preload("Identities").
+		First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func UpdateMe(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+
+	var req UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	config.DB.Create(&user)
+	var user models.User
+	if err := dbFor(c).Where("id = ?", principal.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+			return
+		}
+		user.Name = name
+	}
+	if req.Picture != nil {
+		user.Picture = *req.Picture
+	}
+
+	if req.Preferences != nil {
+		preferences := *req.Preferences
+		preferences.Currency = strings.ToUpper(preferences.Currency)
+		preferences.Benchmark = strings.ToUpper(preferences.Benchmark)
+
+		if preferences.Timezone != "" {
+			if _, err := time.LoadLocation(preferences.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+				return
+			}
+		}
+
+		if preferences.DefaultPortfolioID != "" {
+			var count int64
+			dbFor(c).Model(&models.Portfolio{}).
+				Where("id = ? AND user_id = ?", preferences.DefaultPortfolioID, user.ID).
+				Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Default portfolio not found"})
+				return
+			}
+		}
+
+		for _, channel := range preferences.NotificationChannels {
+			if !models.ValidChannel(channel) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "notification_channels must be push, email or webhook"})
+				return
+			}
+		}
+
+		user.Preferences = preferences
+	}
+
+	if err := dbFor(c).Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// RequestAccountDeletion is the first step of deleting an account. The
+// returned token has to be sent back to DeleteMe within a few minutes.
+func RequestAccountDeletion(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+	if principal.APIKeyID != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot delete accounts"})
+		return
+	}
 
-	c.JSON(http.StatusCreated, user)
+	token, expiresAt, err := auth.IssueConfirmationToken(principal.UserID, accountDeletionPurpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start account deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"confirmation_token": token,
+		"expires_at":         expiresAt,
+		"message":            "This permanently deletes your account and all portfolios, stocks and covered calls. Send the confirmation token to DELETE /v1/me to continue.",
+	})
+}
+
+func DeleteMe(c *gin.Context) {
+	principal, _ := middleware.AuthPrincipal(c)
+	if principal.APIKeyID != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot delete accounts"})
+		return
+	}
+
+	var req DeleteMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := auth.VerifyConfirmationToken(req.ConfirmationToken, principal.UserID, accountDeletionPurpose); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		return deleteUserData(tx, principal.UserID)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+func deleteUserData(tx *gorm.DB, userID string) error {
+	if err := tx.Where("corporate_action_id IN (?)",
+		tx.Model(&models.CorporateAction{}).Select("id").Where("user_id = ?", userID),
+	).Delete(&models.CorporateActionAdjustment{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("session_id IN (?)",
+		tx.Model(&models.Session{}).Select("id").Where("user_id = ?", userID),
+	).Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ? OR portfolio_id IN (?)", userID,
+		tx.Unscoped().Model(&models.Portfolio{}).Select("id").Where("user_id = ?", userID),
+	).Delete(&models.PortfolioMember{}).Error; err != nil {
+		return err
+	}
+
+	// Changes the user made to portfolios shared with them stay in the
+	// owners' history, without pointing at the deleted account.
+	if err := tx.Model(&models.AuditEvent{}).Where("actor_id = ?", userID).
+		Update("actor_id", nil).Error; err != nil {
+		return err
+	}
+
+	for _, model := range accountDeletionOrder {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	result := tx.Delete(&models.User{}, "id = ?", userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"deltra-backend/events"
+	"deltra-backend/models"
+	"deltra-backend/query"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types" binding:"required"`
+}
+
+type UpdateWebhookEndpointRequest struct {
+	URL          *string   `json:"url,omitempty"`
+	Description  *string   `json:"description,omitempty"`
+	EventTypes   *[]string `json:"event_types,omitempty"`
+	Enabled      *bool     `json:"enabled,omitempty"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+type WebhookEndpointResponse struct {
+	models.WebhookEndpoint
+	Secret string `json:"secret,omitempty"`
+}
+
+var webhookDeliveryList = query.Spec{
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-created_at",
+	Filters: []query.Filter{
+		query.Equals("status", "status"),
+		query.Equals("event_type", "event_type"),
+		query.Equals("event_id", "event_id"),
+		query.DateRange("created", "created_at"),
+	},
+}
+
+func validateEventTypes(eventTypes []string) string {
+	if len(eventTypes) == 0 {
+		return "event_types must include at least one event type"
+	}
+	for _, eventType := range eventTypes {
+		if !events.ValidType(eventType) {
+			return "unknown event type: " + eventType
+		}
+	}
+	return ""
+}
+
+func GetWebhookEndpoints(c *gin.Context) {
+	userID := c.Param("id")
+
+	var endpoints []models.WebhookEndpoint
+	if err := dbFor(c).Where("user_id = ?", userID).Order("created_at ASC").Find(&endpoints).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoints)
+}
+
+// CreateWebhookEndpoint registers an endpoint. The signing secret is only
+// returned here and when rotated.
+func CreateWebhookEndpoint(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req CreateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateWebhookURL(req.URL); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := validateEventTypes(req.EventTypes); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{
+		UserID:      userID,
+		URL:         req.URL,
+		Description: strings.TrimSpace(req.Description),
+		EventTypes:  req.EventTypes,
+		Secret:      secret,
+		Enabled:     true,
+	}
+	if err := dbFor(c).Create(&endpoint).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookEndpointResponse{WebhookEndpoint: endpoint, Secret: secret})
+}
+
+func UpdateWebhookEndpoint(c *gin.Context) {
+	userID := c.Param("id")
+	webhookID := c.Param("webhookId")
+
+	var req UpdateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := dbFor(c).Where("id = ? AND user_id = ?", webhookID, userID).First(&endpoint).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if req.URL != nil {
+		if msg := validateWebhookURL(*req.URL); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		endpoint.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		if msg := validateEventTypes(*req.EventTypes); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		endpoint.EventTypes = *req.EventTypes
+	}
+	if req.Description != nil {
+		endpoint.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Enabled != nil {
+		endpoint.Enabled = *req.Enabled
+	}
+
+	response := WebhookEndpointResponse{}
+	if req.RotateSecret {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+		endpoint.Secret = secret
+		response.Secret = secret
+	}
+
+	if err := dbFor(c).Save(&endpoint).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	response.WebhookEndpoint = endpoint
+	c.JSON(http.StatusOK, response)
+}
+
+func DeleteWebhookEndpoint(c *gin.Context) {
+	userID := c.Param("id")
+	webhookID := c.Param("webhookId")
+
+	result := dbFor(c).Where("id = ? AND user_id = ?", webhookID, userID).Delete(&models.WebhookEndpoint{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	userID := c.Param("id")
+	webhookID := c.Param("webhookId")
+
+	var deliveries []models.WebhookDelivery
+	if !findList(c, webhookDeliveryList,
+		dbFor(c).Where("endpoint_id = ? AND user_id = ?", webhookID, userID),
+		&deliveries, "Failed to fetch deliveries") {
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ReplayWebhookDelivery sends a logged event again as a new delivery, leaving
+// the original's log intact.
+func ReplayWebhookDelivery(c *gin.Context) {
+	userID := c.Param("id")
+	webhookID := c.Param("webhookId")
+	deliveryID := c.Param("deliveryId")
+
+	var original models.WebhookDelivery
+	if err := dbFor(c).Where("id = ? AND endpoint_id = ? AND user_id = ?", deliveryID, webhookID, userID).
+		First(&original).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	var replay models.WebhookDelivery
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		replay, err = events.Replay(tx, original)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, replay)
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"deltra-backend/metrics"
+	"sync"
+)
+
+// subscriberBuffer is how far a stream may fall behind before it is dropped.
+// A dropped client reconnects and refetches, which beats stalling publishers.
+const subscriberBuffer = 64
+
+// Change is an event together with the users allowed to see it.
+type Change struct {
+	Event   Event
+	UserIDs []string
+}
+
+// Bus fans committed changes out to live streams. The in-process bus only
+// reaches streams on this server; running several instances needs a shared
+// implementation, e.g. Postgres LISTEN/NOTIFY, installed with UseBus.
+type Bus interface {
+	Publish(change Change)
+	// Subscribe returns the user's events until cancel is called or the
+	// channel is closed because the subscriber fell behind.
+	Subscribe(userID string) (events <-chan Event, cancel func())
+}
+
+var bus Bus = NewMemoryBus()
+
+func UseBus(b Bus) {
+	bus = b
+}
+
+// Broadcast sends changes to live streams. Call it only once the
+// transaction that published them has committed.
+func Broadcast(changes ...Change) {
+	for _, change := range changes {
+		metrics.PortfolioEvents.WithLabelValues(change.Event.Type).Inc()
+		bus.Publish(change)
+	}
+}
+
+func Subscribe(userID string) (<-chan Event, func()) {
+	return bus.Subscribe(userID)
+}
+
+type MemoryBus struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+func (b *MemoryBus) Publish(change Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, userID := range change.UserIDs {
+		for ch := range b.subscribers[userID] {
+			select {
+			case ch <- change.Event:
+			default:
+				b.remove(userID, ch)
+			}
+		}
+	}
+}
+
+func (b *MemoryBus) Subscribe(userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = map[chan Event]struct{}{}
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, ch)
+	}
+}
+
+// remove must be called with b.mu held. It is a no-op for a channel that
+// was already removed, so cancel is safe after a drop.
+func (b *MemoryBus) remove(userID string, ch chan Event) {
+	if _, ok := b.subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(b.subscribers[userID], ch)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	close(ch)
+}
@@ -0,0 +1,139 @@
+// Package events turns recorded changes into portfolio events and hands
+// them to subscribers. Events come from audit records, so anything audited
+// is published, in the same transaction as the change itself.
+package events
+
+import (
+	"deltra-backend/models"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PortfolioCreated = "portfolio.created"
+	PortfolioUpdated = "portfolio.updated"
+	PortfolioDeleted = "portfolio.deleted"
+	StockCreated     = "stock.created"
+	StockUpdated     = "stock.updated"
+	StockDeleted     = "stock.deleted"
+	CallCreated      = "call.created"
+	CallUpdated      = "call.updated"
+	CallDeleted      = "call.deleted"
+	CallActivated    = "call.activated"
+	CallAssigned     = "call.assigned"
+	CallBoughtBack   = "call.bought_back"
+	CallExpired      = "call.expired"
+)
+
+// Types lists every event type that can be subscribed to.
+var Types = []string{
+	PortfolioCreated, PortfolioUpdated, PortfolioDeleted,
+	StockCreated, StockUpdated, StockDeleted,
+	CallCreated, CallUpdated, CallDeleted,
+	CallActivated, CallAssigned, CallBoughtBack, CallExpired,
+}
+
+// callStatusEvents replaces call.updated when a call's status moves.
+var callStatusEvents = map[string]string{
+	"active":      CallActivated,
+	"assigned":    CallAssigned,
+	"bought_back": CallBoughtBack,
+	"expired":     CallExpired,
+}
+
+// Event is the payload delivered to subscribers. Object is the entity after
+// the change, or as it was before a delete; Previous is set on updates.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	PortfolioID string      `json:"portfolio_id"`
+	EntityType  string      `json:"entity_type"`
+	EntityID    string      `json:"entity_id"`
+	ActorID     string      `json:"actor_id,omitempty"`
+	Object      models.JSON `json:"object"`
+	Previous    models.JSON `json:"previous,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+func ValidType(eventType string) bool {
+	if eventType == "*" {
+		return true
+	}
+	for _, known := range Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// FromAudit derives the event for an audit record. A revert is reported as
+// whatever it did to the entity.
+func FromAudit(audit models.AuditEvent) Event {
+	event := Event{
+		ID:          audit.ID,
+		PortfolioID: audit.PortfolioID,
+		EntityType:  audit.EntityType,
+		EntityID:    audit.EntityID,
+		CreatedAt:   audit.CreatedAt,
+	}
+
+	if audit.ActorID != nil {
+		event.ActorID = *audit.ActorID
+	}
+
+	prefix := audit.EntityType
+	if prefix == "covered_call" {
+		prefix = "call"
+	}
+
+	hasBefore, hasAfter := !isNull(audit.Before), !isNull(audit.After)
+	switch {
+	case !hasBefore:
+		event.Type = prefix + ".created"
+		event.Object = audit.After
+	case !hasAfter:
+		event.Type = prefix + ".deleted"
+		event.Object = audit.Before
+	default:
+		event.Type = prefix + ".updated"
+		event.Object = audit.After
+		event.Previous = audit.Before
+		if prefix == "call" {
+			if status, changed := statusChange(audit.Before, audit.After); changed && callStatusEvents[status] != "" {
+				event.Type = callStatusEvents[status]
+			}
+		}
+	}
+
+	return event
+}
+
+func isNull(data models.JSON) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func statusChange(before, after models.JSON) (string, bool) {
+	var from, to struct {
+		Status string `json:"status"`
+	}
+	json.Unmarshal(before, &from)
+	json.Unmarshal(after, &to)
+	return to.Status, from.Status != to.Status
+}
+
+// Publish queues webhook deliveries for an event as part of tx, so nothing
+// is sent for a change that rolls back. The returned change is for
+// Broadcast once tx has committed.
+func Publish(tx *gorm.DB, event Event) (Change, error) {
+	change := Change{Event: event}
+	if err := tx.Model(&models.PortfolioMember{}).
+		Where("portfolio_id = ? AND status = ?", event.PortfolioID, models.MembershipAccepted).
+		Pluck("user_id", &change.UserIDs).Error; err != nil {
+		return change, err
+	}
+
+	return change, queueWebhooks(tx, change)
+}
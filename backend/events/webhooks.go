@@ -0,0 +1,144 @@
+package events
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"deltra-backend/notify"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	webhookBatch = 100
+
+	// webhookLease has to outlast sending a whole batch, one timeout each.
+	webhookLease = 20 * time.Minute
+)
+
+var webhookClient = notify.NewWebhookClient()
+
+// queueWebhooks adds a pending delivery for every enabled endpoint whose
+// owner can see the change and has subscribed to its type.
+func queueWebhooks(tx *gorm.DB, change Change) error {
+	if len(change.UserIDs) == 0 {
+		return nil
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := tx.Where("enabled = ? AND user_id IN ?", true, change.UserIDs).
+		Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	event := change.Event
+
+	var deliveries []models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribed(event.Type) {
+			continue
+		}
+		payload, err := models.NewJSON(event)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			UserID:        endpoint.UserID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// DeliverWebhooks sends due webhook deliveries, retrying failures with the
+// same backoff as notifications. Like notifications, they're claimed first,
+// sent outside any transaction and each result is written on its own.
+func DeliverWebhooks(ctx context.Context) error {
+	var deliveries []models.WebhookDelivery
+	if err := notify.ClaimDue(ctx, &deliveries, webhookBatch, webhookLease); err != nil {
+		return err
+	}
+
+	db := config.DB.WithContext(context.WithoutCancel(ctx))
+	var errs []error
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			// Unsent claims are retried once their lease runs out.
+			return errors.Join(append(errs, err)...)
+		}
+		if err := deliverWebhook(ctx, db, &delivery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func deliverWebhook(ctx context.Context, db *gorm.DB, delivery *models.WebhookDelivery) error {
+	var endpoint models.WebhookEndpoint
+	err := db.Where("id = ?", delivery.EndpointID).First(&endpoint).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	start := time.Now()
+	var sendErr error
+	switch {
+	case err != nil:
+		sendErr = notify.Permanent(errors.New("endpoint deleted"))
+	case !endpoint.Enabled:
+		sendErr = notify.Permanent(errors.New("endpoint disabled"))
+	default:
+		delivery.ResponseStatus, sendErr = notify.PostSigned(ctx, webhookClient,
+			endpoint.URL, endpoint.Secret, delivery.EventType, delivery.ID, delivery.Payload)
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.DurationMs = now.Sub(start).Milliseconds()
+
+	var permanent *notify.PermanentError
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliverySent
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case errors.As(sendErr, &permanent) || delivery.Attempts >= notify.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(notify.Backoff(delivery.Attempts))
+	}
+
+	return db.Model(delivery).
+		Select("status", "attempts", "next_attempt_at", "last_error", "delivered_at", "response_status", "duration_ms").
+		Updates(delivery).Error
+}
+
+// Replay queues a fresh delivery of a logged one, for endpoints that missed
+// or mishandled it.
+func Replay(tx *gorm.DB, original models.WebhookDelivery) (models.WebhookDelivery, error) {
+	replay := models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		UserID:        original.UserID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+		ReplayOf:      &original.ID,
+	}
+	err := tx.Create(&replay).Error
+	return replay, err
+}
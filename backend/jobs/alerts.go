@@ -0,0 +1,296 @@
+package jobs
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/notify"
+	"deltra-backend/performance"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultProfitCaptured = 50
+	DefaultExpirationDays = 7
+)
+
+// AlertInterval is how often alert rules are checked, from ALERT_INTERVAL
+// (default 15m).
+func AlertInterval() time.Duration {
+	value := os.Getenv("ALERT_INTERVAL")
+	if value == "" {
+		return 15 * time.Minute
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < time.Minute {
+		slog.Warn("Invalid ALERT_INTERVAL, using 15m", "value", value)
+		return 15 * time.Minute
+	}
+	return interval
+}
+
+// alertCheck is one rule evaluated against one call or stock. ok is false
+// when there wasn't enough market data to decide either way.
+type alertCheck struct {
+	subject   string
+	triggered bool
+	ok        bool
+	event     models.AlertEvent
+}
+
+type alertQuotes struct {
+	ctx    context.Context
+	prices map[string]*float64
+}
+
+func (q *alertQuotes) price(symbol string) (float64, bool) {
+	if price, seen := q.prices[symbol]; seen {
+		return deref(price)
+	}
+	var price *float64
+	if quote, err := marketdata.GetQuote(q.ctx, symbol); err == nil {
+		price = &quote.Price
+	}
+	q.prices[symbol] = price
+	return deref(price)
+}
+
+func (q *alertQuotes) callPrice(call models.CoveredCall) (float64, bool) {
+	return q.price(marketdata.OptionSymbol(call.Stock.Symbol, call.ExpirationDate, call.StrikePrice))
+}
+
+func deref(price *float64) (float64, bool) {
+	if price == nil {
+		return 0, false
+	}
+	return *price, true
+}
+
+// EvaluateAlerts checks every enabled rule, opening an event the first time
+// its condition holds for a call or stock and clearing it once it no longer
+// does. A rule that fails is logged and skipped so the rest still run.
+func EvaluateAlerts(ctx context.Context) error {
+	var rules []models.AlertRule
+	if err := config.DB.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		return err
+	}
+
+	quotes := &alertQuotes{ctx: ctx, prices: make(map[string]*float64)}
+	var errs []error
+	for _, rule := range rules {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err := evaluateRule(rule, quotes, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Failed to evaluate alert rule", "rule_id", rule.ID, "error", err)
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func evaluateRule(rule models.AlertRule, quotes *alertQuotes, now time.Time) error {
+	var checks []alertCheck
+
+	if rule.Type == models.AlertBelowAdjustedBasis {
+		var stocks []models.Stock
+		if err := ruleScope(config.DB, rule).
+			Preload("CoveredCalls").
+			Preload("Dividends").
+			Find(&stocks).Error; err != nil {
+			return err
+		}
+		for _, stock := range stocks {
+			checks = append(checks, checkStock(stock, quotes))
+		}
+	} else {
+		var calls []models.CoveredCall
+		if err := ruleScope(config.DB, rule).
+			Where("status = ?", "active").
+			Preload("Stock").
+			Find(&calls).Error; err != nil {
+			return err
+		}
+		for _, call := range calls {
+			checks = append(checks, checkCall(rule, call, quotes, now))
+		}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		subjects := make([]string, 0, len(checks))
+		for _, check := range checks {
+			subjects = append(subjects, check.subject)
+			if !check.ok {
+				continue
+			}
+			if check.triggered {
+				check.event.RuleID = rule.ID
+				check.event.UserID = rule.UserID
+				check.event.Type = rule.Type
+				check.event.Subject = check.subject
+				check.event.TriggeredAt = now
+				// The partial unique index keeps a single open event per
+				// subject, so a rule that still holds adds nothing.
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&check.event)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected > 0 {
+					if err := notifyAlert(tx, check.event); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := tx.Model(&models.AlertEvent{}).
+				Where("rule_id = ? AND subject = ? AND cleared_at IS NULL", rule.ID, check.subject).
+				Update("cleared_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		// Calls that closed and stocks that left the rule's scope aren't
+		// checked any more, so their open events would never clear.
+		stale := tx.Model(&models.AlertEvent{}).Where("rule_id = ? AND cleared_at IS NULL", rule.ID)
+		if len(subjects) > 0 {
+			stale = stale.Where("subject NOT IN ?", subjects)
+		}
+		if err := stale.Update("cleared_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&rule).Update("last_evaluated_at", now).Error
+	})
+}
+
+func notifyAlert(tx *gorm.DB, event models.AlertEvent) error {
+	_, err := notify.Enqueue(tx, event.UserID, notify.Message{
+		Kind:  "alert",
+		Title: event.Symbol + " alert",
+		Body:  event.Message,
+		Data: map[string]any{
+			"alert_event_id":  event.ID,
+			"rule_id":         event.RuleID,
+			"type":            event.Type,
+			"portfolio_id":    event.PortfolioID,
+			"stock_id":        event.StockID,
+			"covered_call_id": event.CoveredCallID,
+		},
+	})
+	return err
+}
+
+// ruleScope limits a call or stock query to what the rule covers and the
+// rule's owner can still see.
+func ruleScope(db *gorm.DB, rule models.AlertRule) *gorm.DB {
+	db = db.Where("portfolio_id IN (?)", config.DB.Model(&models.PortfolioMember{}).
+		Select("portfolio_id").
+		Where("user_id = ? AND status = ?", rule.UserID, models.MembershipAccepted))
+	if rule.PortfolioID != nil {
+		db = db.Where("portfolio_id = ?", *rule.PortfolioID)
+	}
+	if rule.StockID != nil {
+		if rule.Type == models.AlertBelowAdjustedBasis {
+			db = db.Where("id = ?", *rule.StockID)
+		} else {
+			db = db.Where("stock_id = ?", *rule.StockID)
+		}
+	}
+	return db
+}
+
+func checkCall(rule models.AlertRule, call models.CoveredCall, quotes *alertQuotes, now time.Time) alertCheck {
+	symbol := strings.ToUpper(call.Stock.Symbol)
+	check := alertCheck{
+		subject: "call:" + call.ID,
+		event: models.AlertEvent{
+			PortfolioID:   call.PortfolioID,
+			StockID:       call.StockID,
+			CoveredCallID: &call.ID,
+			Symbol:        symbol,
+		},
+	}
+	label := fmt.Sprintf("%s $%.2f call expiring %s", symbol, call.StrikePrice, call.ExpirationDate.Format("2006-01-02"))
+
+	switch rule.Type {
+	case models.AlertCallInTheMoney:
+		price, ok := quotes.price(symbol)
+		if !ok {
+			return check
+		}
+		check.ok = true
+		check.triggered = price > call.StrikePrice
+		check.event.Value = price
+		check.event.Message = fmt.Sprintf("%s is in the money with %s at $%.2f", label, symbol, price)
+
+	case models.AlertProfitCaptured:
+		if call.PremiumReceived <= 0 {
+			return check
+		}
+		optionPrice, ok := quotes.callPrice(call)
+		if !ok {
+			return check
+		}
+		threshold := rule.Threshold
+		if threshold == 0 {
+			threshold = DefaultProfitCaptured
+		}
+		captured := (call.PremiumReceived - optionPrice) / call.PremiumReceived * 100
+		check.ok = true
+		check.triggered = captured >= threshold
+		check.event.Value = captured
+		check.event.Message = fmt.Sprintf("%s has captured %.0f%% of max profit ($%.2f to buy back)", label, captured, optionPrice)
+
+	case models.AlertExpirationNear:
+		days := rule.Threshold
+		if days == 0 {
+			days = DefaultExpirationDays
+		}
+		remaining := performance.Day(call.ExpirationDate).Sub(performance.Day(now)).Hours() / 24
+		check.ok = true
+		check.triggered = remaining >= 0 && remaining <= days
+		check.event.Value = remaining
+		check.event.Message = fmt.Sprintf("%s expires in %.0f days", label, remaining)
+	}
+
+	return check
+}
+
+func checkStock(stock models.Stock, quotes *alertQuotes) alertCheck {
+	symbol := strings.ToUpper(stock.Symbol)
+	check := alertCheck{
+		subject: "stock:" + stock.ID,
+		event: models.AlertEvent{
+			PortfolioID: stock.PortfolioID,
+			StockID:     stock.ID,
+			Symbol:      symbol,
+		},
+	}
+
+	// A closed position has no basis to fall below, so any open event
+	// resolves.
+	if stock.Shares <= 0 {
+		check.ok = true
+		return check
+	}
+	price, ok := quotes.price(symbol)
+	if !ok {
+		return check
+	}
+
+	stock.CalculateMetrics()
+	check.ok = true
+	check.triggered = price < stock.AdjustedBasis
+	check.event.Value = price
+	check.event.Message = fmt.Sprintf("%s at $%.2f is below its adjusted basis of $%.2f", symbol, price, stock.AdjustedBasis)
+	return check
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"deltra-backend/audit"
+	"deltra-backend/config"
+	"deltra-backend/events"
+	"deltra-backend/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyReinvestments adds the shares of reinvested dividends whose pay date
+// has arrived. Each dividend is applied in its own transaction, so one
+// failure doesn't hold back the rest.
+func ApplyReinvestments(ctx context.Context) error {
+	now := time.Now()
+	db := config.DB.WithContext(ctx)
+
+	var due []models.Dividend
+	if err := db.Where("reinvested_shares > 0 AND reinvested_at IS NULL AND pay_date <= ?", now).
+		Find(&due).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, dividend := range due {
+		if err := applyReinvestment(db, dividend.ID, now); err != nil {
+			errs = append(errs, fmt.Errorf("dividend %s: %w", dividend.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func applyReinvestment(db *gorm.DB, dividendID string, now time.Time) error {
+	var change events.Change
+	applied := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var dividend models.Dividend
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", dividendID).
+			First(&dividend).Error; err != nil {
+			return err
+		}
+		if !dividend.ReinvestmentDue(now) {
+			return nil
+		}
+
+		var stock models.Stock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", dividend.StockID).
+			First(&stock).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			// The stock is in the trash; the shares are added if it's restored
+			// and the job runs again.
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		before := audit.State(&stock)
+		dividend.Reinvest(&stock, now)
+		if err := tx.Save(&stock).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&dividend).Error; err != nil {
+			return err
+		}
+
+		var err error
+		change, err = audit.Record(tx, audit.System, models.AuditUpdate, &stock, before)
+		applied = err == nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		events.Broadcast(change)
+	}
+	return nil
+}
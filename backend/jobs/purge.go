@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TrashRetention is how long soft-deleted portfolios, stocks and covered
+// calls stay restorable, from TRASH_RETENTION_DAYS (default 30).
+func TrashRetention() time.Duration {
+	days := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			slog.Warn("Invalid TRASH_RETENTION_DAYS, using default", "value", value, "days", days)
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// portfolioPurgeOrder lists the rows scoped to a portfolio, children-first
+// so foreign keys hold. It mirrors the account deletion order.
+var portfolioPurgeOrder = []any{
+	&models.StockSnapshot{},
+	&models.PortfolioSnapshot{},
+	&models.CashTransaction{},
+	&models.AlertEvent{},
+	&models.Dividend{},
+	&models.CoveredCall{},
+	&models.CorporateAction{},
+	&models.Stock{},
+	&models.ShareLink{},
+	&models.FeeSchedule{},
+	&models.PortfolioMember{},
+	&models.AuditEvent{},
+}
+
+// PurgeDeleted permanently removes rows that have been in the trash longer
+// than the retention period, along with everything that only makes sense
+// alongside them. Children go first so foreign keys hold.
+func PurgeDeleted(ctx context.Context) error {
+	cutoff := time.Now().Add(-TrashRetention())
+
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		db := tx.Unscoped().Session(&gorm.Session{})
+
+		calls := db.Model(&models.CoveredCall{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgeCalls(db, calls); err != nil {
+			return err
+		}
+		if err := db.Where("deleted_at < ?", cutoff).Delete(&models.CoveredCall{}).Error; err != nil {
+			return err
+		}
+
+		stocks := db.Model(&models.Stock{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgeStocks(db, stocks); err != nil {
+			return err
+		}
+		if err := db.Where("deleted_at < ?", cutoff).Delete(&models.Stock{}).Error; err != nil {
+			return err
+		}
+
+		portfolios := db.Model(&models.Portfolio{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := purgePortfolios(db, portfolios); err != nil {
+			return err
+		}
+		return db.Where("deleted_at < ?", cutoff).Delete(&models.Portfolio{}).Error
+	})
+}
+
+// purgeCalls detaches cash from calls about to be purged; the cash itself
+// stays, since the portfolio's balance still includes it.
+func purgeCalls(db *gorm.DB, calls *gorm.DB) error {
+	if err := db.Where("covered_call_id IN (?)", calls).Delete(&models.AlertEvent{}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.CashTransaction{}).
+		Where("covered_call_id IN (?)", calls).
+		Update("covered_call_id", nil).Error
+}
+
+// purgeStocks removes what hangs off stocks about to be purged from a
+// portfolio that is still around. Cash and audit history stay with the
+// portfolio.
+func purgeStocks(db *gorm.DB, stocks *gorm.DB) error {
+	calls := db.Model(&models.CoveredCall{}).Select("id").Where("stock_id IN (?)", stocks)
+	if err := purgeCalls(db, calls); err != nil {
+		return err
+	}
+
+	actions := db.Model(&models.CorporateAction{}).Select("id").Where("stock_id IN (?)", stocks)
+	if err := db.Where("corporate_action_id IN (?)", actions).Delete(&models.CorporateActionAdjustment{}).Error; err != nil {
+		return err
+	}
+
+	for _, model := range []any{
+		&models.StockSnapshot{},
+		&models.AlertEvent{},
+		&models.AlertRule{},
+		&models.Dividend{},
+		&models.CoveredCall{},
+		&models.CorporateAction{},
+	} {
+		if err := db.Where("stock_id IN (?)", stocks).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Model(&models.CashTransaction{}).
+		Where("stock_id IN (?)", stocks).
+		Update("stock_id", nil).Error
+}
+
+// purgePortfolios removes every row scoped to portfolios about to be purged,
+// including ones that were never trashed themselves.
+func purgePortfolios(db *gorm.DB, portfolios *gorm.DB) error {
+	actions := db.Model(&models.CorporateAction{}).Select("id").Where("portfolio_id IN (?)", portfolios)
+	if err := db.Where("corporate_action_id IN (?)", actions).Delete(&models.CorporateActionAdjustment{}).Error; err != nil {
+		return err
+	}
+
+	stocks := db.Model(&models.Stock{}).Select("id").Where("portfolio_id IN (?)", portfolios)
+	if err := db.Where("stock_id IN (?)", stocks).Delete(&models.AlertRule{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("portfolio_id IN (?)", portfolios).Delete(&models.AlertRule{}).Error; err != nil {
+		return err
+	}
+
+	for _, model := range portfolioPurgeOrder {
+		if err := db.Where("portfolio_id IN (?)", portfolios).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
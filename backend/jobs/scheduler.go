@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"deltra-backend/events"
+	"deltra-backend/metrics"
+	"deltra-backend/notify"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func Start(ctx context.Context) {
+	hour, minute := snapshotTime()
+	DailyAt(ctx, "portfolio_snapshots", hour, minute, RecordDailySnapshots)
+	DailyAt(ctx, "purge_deleted", 4, 0, PurgeDeleted)
+	DailyAt(ctx, "dividend_reinvestments", 0, 5, ApplyReinvestments)
+	Every(ctx, "alerts", AlertInterval(), EvaluateAlerts)
+	Every(ctx, "notifications", 30*time.Second, notify.DeliverPending)
+	Every(ctx, "webhooks", 30*time.Second, events.DeliverWebhooks)
+}
+
+// DailyAt runs fn once a day at the given UTC time.
+func DailyAt(ctx context.Context, name string, hour, minute int, fn func(context.Context) error) {
+	go func() {
+		for {
+			now := time.Now().UTC()
+			next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.UTC)
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(next)):
+				run(ctx, name, fn)
+			}
+		}
+	}()
+}
+
+// Every runs fn on a fixed interval, starting one interval from now.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run(ctx, name, fn)
+			}
+		}
+	}()
+}
+
+func run(ctx context.Context, name string, fn func(context.Context) error) {
+	start := time.Now()
+	err := fn(ctx)
+	elapsed := time.Since(start)
+	metrics.JobDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+
+	if err != nil {
+		metrics.JobRuns.WithLabelValues(name, "failure").Inc()
+		slog.ErrorContext(ctx, "Job failed", "job", name, "duration_ms", elapsed.Milliseconds(), "error", err)
+		return
+	}
+	metrics.JobRuns.WithLabelValues(name, "success").Inc()
+	slog.InfoContext(ctx, "Job completed", "job", name, "duration_ms", elapsed.Milliseconds())
+}
+
+// snapshotTime reads SNAPSHOT_TIME as HH:MM in UTC, defaulting to shortly
+// after the US market close.
+func snapshotTime() (int, int) {
+	value := os.Getenv("SNAPSHOT_TIME")
+	if value == "" {
+		return 21, 30
+	}
+
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		slog.Warn("Invalid SNAPSHOT_TIME, using 21:30", "value", value)
+		return 21, 30
+	}
+	return parsed.Hour(), parsed.Minute()
+}
@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/marketdata"
+	"deltra-backend/models"
+	"deltra-backend/performance"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordDailySnapshots values every portfolio at today's quotes. A portfolio
+// that fails is logged and skipped so the rest still get their snapshot.
+func RecordDailySnapshots(ctx context.Context) error {
+	day := performance.Day(time.Now())
+	db := config.DB.WithContext(ctx)
+
+	var portfolios []models.Portfolio
+	if err := db.Find(&portfolios).Error; err != nil {
+		return err
+	}
+
+	quotes := make(map[string]float64)
+	prices := func(symbol string, _ time.Time) (float64, bool) {
+		symbol = strings.ToUpper(symbol)
+		if price, ok := quotes[symbol]; ok {
+			return price, true
+		}
+		quote, err := marketdata.GetQuote(ctx, symbol)
+		if err != nil {
+			return 0, false
+		}
+		quotes[symbol] = quote.Price
+		return quote.Price, true
+	}
+
+	var errs []error
+	for _, portfolio := range portfolios {
+		ledger, err := performance.LoadLedger(db, portfolio.ID)
+		if err == nil {
+			_, err = snapshotPortfolio(db, portfolio, ledger, []time.Time{day}, prices, true)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to snapshot portfolio", "portfolio_id", portfolio.ID, "error", err)
+			errs = append(errs, fmt.Errorf("portfolio %s: %w", portfolio.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Backfill fills in snapshots for every trading day in the range from price
+// history and returns how many days it wrote. Days already snapshotted are
+// kept unless overwrite is set, since those were recorded at the time.
+func Backfill(ctx context.Context, portfolio models.Portfolio, from, to time.Time, overwrite bool) (int, error) {
+	db := config.DB.WithContext(ctx)
+	ledger, err := performance.LoadLedger(db, portfolio.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	histories := make(map[string][]marketdata.Bar)
+	for _, stock := range ledger.Stocks {
+		symbol := strings.ToUpper(stock.Symbol)
+		if _, ok := histories[symbol]; ok {
+			continue
+		}
+		bars, err := marketdata.GetHistory(ctx, symbol, from.AddDate(0, 0, -7), to)
+		if err != nil {
+			return 0, err
+		}
+		histories[symbol] = bars
+	}
+
+	prices := func(symbol string, day time.Time) (float64, bool) {
+		return marketdata.CloseOn(histories[strings.ToUpper(symbol)], day)
+	}
+
+	return snapshotPortfolio(db, portfolio, ledger, performance.TradingDays(from, to), prices, overwrite)
+}
+
+// snapshotPortfolio writes a snapshot for each day and returns how many it
+// wrote. Without overwrite, days that already have one are skipped whole.
+func snapshotPortfolio(db *gorm.DB, portfolio models.Portfolio, ledger performance.Ledger, days []time.Time, prices performance.PriceFunc, overwrite bool) (int, error) {
+	portfolioConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "portfolio_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cash", "stock_value", "open_call_liability", "cumulative_premium", "total_value", "updated_at"}),
+	}
+	stockConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "stock_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"symbol", "shares", "price", "market_value", "open_call_liability", "cumulative_premium", "updated_at"}),
+	}
+	if !overwrite {
+		portfolioConflict = clause.OnConflict{Columns: portfolioConflict.Columns, DoNothing: true}
+		stockConflict = clause.OnConflict{Columns: stockConflict.Columns, DoNothing: true}
+	}
+
+	written := 0
+	for _, day := range days {
+		value := ledger.ValueOn(day, prices)
+		snapshot := models.PortfolioSnapshot{
+			PortfolioID:       portfolio.ID,
+			UserID:            portfolio.UserID,
+			Date:              day,
+			Cash:              value.Cash,
+			StockValue:        value.StockValue,
+			OpenCallLiability: value.OpenCallLiability,
+			CumulativePremium: value.CumulativePremium,
+			TotalValue:        value.TotalValue,
+		}
+
+		stockSnapshots := make([]models.StockSnapshot, 0, len(value.Stocks))
+		for _, stockValue := range value.Stocks {
+			stockSnapshots = append(stockSnapshots, models.StockSnapshot{
+				StockID:           stockValue.Stock.ID,
+				PortfolioID:       portfolio.ID,
+				UserID:            stockValue.Stock.UserID,
+				Date:              day,
+				Symbol:            stockValue.Stock.Symbol,
+				Shares:            stockValue.Shares,
+				Price:             stockValue.Price,
+				MarketValue:       stockValue.MarketValue,
+				OpenCallLiability: stockValue.OpenCallLiability,
+				CumulativePremium: stockValue.CumulativePremium,
+			})
+		}
+
+		result := db.Clauses(portfolioConflict).Create(&snapshot)
+		if result.Error != nil {
+			return written, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		written++
+
+		if len(stockSnapshots) > 0 {
+			if err := db.Clauses(stockConflict).Create(&stockSnapshots).Error; err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
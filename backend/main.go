@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"deltra-backend/auth"
 	"deltra-backend/config"
+	"deltra-backend/jobs"
+	"deltra-backend/logging"
+	"deltra-backend/marketdata"
+	"deltra-backend/middleware"
+	"deltra-backend/notify"
+	"deltra-backend/query"
 	"deltra-backend/routes"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -13,17 +23,31 @@ import (
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	envErr := godotenv.Load()
+	logging.Init()
+	if envErr != nil {
+		slog.Info("No .env file found, using system environment variables")
 	}
 
+	if err := auth.LoadKeys(); err != nil {
+		logging.Fatal("Failed to load JWT keys", "error", err)
+	}
+	reloadKeysOnHangup()
+
 	config.InitDB()
+	marketdata.Init()
+	notify.Init()
+	jobs.Start(context.Background())
 
 	if os.Getenv("GIN_MODE") == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.RequestLogger())
+	r.Use(middleware.RequestMetrics())
+	r.Use(middleware.Recovery())
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{
@@ -32,10 +56,12 @@ func main() {
 			"http://localhost:8081",
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{middleware.RequestIDHeader, query.TotalCountHeader, query.NextCursorHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
+	r.Use(middleware.BroadcastChanges())
 
 	routes.SetupRoutes(r)
 
@@ -44,9 +70,26 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on port %s", port)
+	slog.Info("Server starting", "port", port)
 
 	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		logging.Fatal("Failed to start server", "error", err)
 	}
 }
+
+// reloadKeysOnHangup re-reads JWT keys on SIGHUP so signing keys can be
+// rotated without restarting the server.
+func reloadKeysOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			if err := auth.LoadKeys(); err != nil {
+				slog.Error("Failed to reload JWT keys, keeping current keys", "error", err)
+				continue
+			}
+			slog.Info("Reloaded JWT keys")
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package marketdata
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cachedQuote struct {
+	quote   Quote
+	expires time.Time
+}
+
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	quotes map[string]cachedQuote
+}
+
+func NewCache(provider Provider, ttl time.Duration) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		quotes:   make(map[string]cachedQuote),
+	}
+}
+
+func (c *Cache) Quote(ctx context.Context, symbol string) (Quote, error) {
+	symbol = strings.ToUpper(symbol)
+
+	c.mu.Lock()
+	cached, ok := c.quotes[symbol]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.quote, nil
+	}
+
+	quote, err := c.provider.Quote(ctx, symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	c.mu.Lock()
+	c.quotes[symbol] = cachedQuote{quote: quote, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return quote, nil
+}
+
+func (c *Cache) History(ctx context.Context, symbol string, from, to time.Time) ([]Bar, error) {
+	return c.provider.History(ctx, symbol, from, to)
+}
@@ -0,0 +1,100 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const polygonBaseURL = "https://api.polygon.io"
+
+type Polygon struct {
+	apiKey string
+	client *http.Client
+}
+
+type polygonAggregates struct {
+	Status  string `json:"status"`
+	Results []struct {
+		Close     float64 `json:"c"`
+		Timestamp int64   `json:"t"`
+	} `json:"results"`
+}
+
+func NewPolygon(apiKey string) *Polygon {
+	return &Polygon{
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Polygon) Quote(ctx context.Context, symbol string) (Quote, error) {
+	symbol = strings.ToUpper(symbol)
+
+	var aggs polygonAggregates
+	if err := p.get(ctx, fmt.Sprintf("/v2/aggs/ticker/%s/prev", url.PathEscape(symbol)), &aggs); err != nil {
+		return Quote{}, err
+	}
+	if len(aggs.Results) == 0 {
+		return Quote{}, fmt.Errorf("no quote for %s", symbol)
+	}
+
+	result := aggs.Results[0]
+	return Quote{
+		Symbol: symbol,
+		Price:  result.Close,
+		Time:   time.UnixMilli(result.Timestamp),
+	}, nil
+}
+
+func (p *Polygon) History(ctx context.Context, symbol string, from, to time.Time) ([]Bar, error) {
+	symbol = strings.ToUpper(symbol)
+	path := fmt.Sprintf("/v2/aggs/ticker/%s/range/1/day/%s/%s",
+		url.PathEscape(symbol), from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	var aggs polygonAggregates
+	if err := p.get(ctx, path, &aggs); err != nil {
+		return nil, err
+	}
+
+	bars := make([]Bar, 0, len(aggs.Results))
+	for _, result := range aggs.Results {
+		// Daily bars are stamped at midnight Eastern; shift into the same
+		// calendar day before truncating so bars line up with UTC dates.
+		day := time.UnixMilli(result.Timestamp).UTC().Add(12 * time.Hour)
+		bars = append(bars, Bar{
+			Date:  time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC),
+			Close: result.Close,
+		})
+	}
+	return bars, nil
+}
+
+func (p *Polygon) get(ctx context.Context, path string, out any) error {
+	query := url.Values{}
+	query.Set("adjusted", "true")
+	query.Set("sort", "asc")
+	query.Set("limit", "50000")
+	query.Set("apiKey", p.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, polygonBaseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("polygon request failed: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
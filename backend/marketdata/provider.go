@@ -0,0 +1,89 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotConfigured = errors.New("market data provider not configured")
+
+type Quote struct {
+	Symbol string    `json:"symbol"`
+	Price  float64   `json:"price"`
+	Time   time.Time `json:"time"`
+}
+
+type Bar struct {
+	Date  time.Time `json:"date"`
+	Close float64   `json:"close"`
+}
+
+type Provider interface {
+	Quote(ctx context.Context, symbol string) (Quote, error)
+	History(ctx context.Context, symbol string, from, to time.Time) ([]Bar, error)
+}
+
+var Default Provider
+
+func Init() {
+	switch name := os.Getenv("MARKET_DATA_PROVIDER"); name {
+	case "":
+		slog.Info("MARKET_DATA_PROVIDER not set, market data disabled")
+	case "polygon":
+		apiKey := os.Getenv("MARKET_DATA_API_KEY")
+		if apiKey == "" {
+			slog.Warn("MARKET_DATA_API_KEY not set, market data disabled")
+			return
+		}
+		Default = NewCache(NewPolygon(apiKey), time.Minute)
+	default:
+		slog.Warn("Unknown MARKET_DATA_PROVIDER, market data disabled", "provider", name)
+	}
+}
+
+func Configured() bool {
+	return Default != nil
+}
+
+func GetQuote(ctx context.Context, symbol string) (Quote, error) {
+	if Default == nil {
+		return Quote{}, ErrNotConfigured
+	}
+	return Default.Quote(ctx, symbol)
+}
+
+func GetHistory(ctx context.Context, symbol string, from, to time.Time) ([]Bar, error) {
+	if Default == nil {
+		return nil, ErrNotConfigured
+	}
+	return Default.History(ctx, symbol, from, to)
+}
+
+// CloseOn returns the last close on or before date, assuming bars are sorted
+// ascending.
+func CloseOn(bars []Bar, date time.Time) (float64, bool) {
+	price, found := 0.0, false
+	for _, bar := range bars {
+		if bar.Date.After(date) {
+			break
+		}
+		price, found = bar.Close, true
+	}
+	return price, found
+}
+
+// OptionSymbol builds the OCC ticker for a call, e.g. O:AAPL250117C00150000.
+func OptionSymbol(underlying string, expiration time.Time, strike float64) string {
+	return fmt.Sprintf("O:%s%sC%08d", strings.ToUpper(underlying), expiration.Format("060102"), int64(math.Round(strike*1000)))
+}
+
+// GetCallQuote prices a call contract by its OCC ticker.
+func GetCallQuote(ctx context.Context, underlying string, expiration time.Time, strike float64) (Quote, error) {
+	return GetQuote(ctx, OptionSymbol(underlying, expiration, strike))
+}
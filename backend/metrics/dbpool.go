@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"deltra-backend/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	dbMaxOpen = prometheus.NewDesc("deltra_db_max_open_connections",
+		"Maximum open database connections.", nil, nil)
+	dbOpen = prometheus.NewDesc("deltra_db_open_connections",
+		"Open database connections.", nil, nil)
+	dbInUse = prometheus.NewDesc("deltra_db_in_use_connections",
+		"Database connections in use.", nil, nil)
+	dbIdle = prometheus.NewDesc("deltra_db_idle_connections",
+		"Idle database connections.", nil, nil)
+	dbWaitCount = prometheus.NewDesc("deltra_db_wait_count_total",
+		"Waits for a database connection.", nil, nil)
+	dbWaitDuration = prometheus.NewDesc("deltra_db_wait_duration_seconds_total",
+		"Time spent waiting for a database connection.", nil, nil)
+	dbMaxIdleClosed = prometheus.NewDesc("deltra_db_max_idle_closed_total",
+		"Connections closed for exceeding the idle limit.", nil, nil)
+	dbMaxIdleTimeClosed = prometheus.NewDesc("deltra_db_max_idle_time_closed_total",
+		"Connections closed for exceeding the idle time.", nil, nil)
+	dbMaxLifetimeClosed = prometheus.NewDesc("deltra_db_max_lifetime_closed_total",
+		"Connections closed for exceeding their lifetime.", nil, nil)
+)
+
+// dbPoolCollector reads the connection pool's statistics at scrape time.
+type dbPoolCollector struct{}
+
+func (dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		dbMaxOpen, dbOpen, dbInUse, dbIdle,
+		dbWaitCount, dbWaitDuration, dbMaxIdleClosed, dbMaxIdleTimeClosed, dbMaxLifetimeClosed,
+	} {
+		ch <- desc
+	}
+}
+
+func (dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	if config.DB == nil {
+		return
+	}
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return
+	}
+	stats := sqlDB.Stats()
+
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(dbMaxOpen, float64(stats.MaxOpenConnections))
+	gauge(dbOpen, float64(stats.OpenConnections))
+	gauge(dbInUse, float64(stats.InUse))
+	gauge(dbIdle, float64(stats.Idle))
+	counter(dbWaitCount, float64(stats.WaitCount))
+	counter(dbWaitDuration, stats.WaitDuration.Seconds())
+	counter(dbMaxIdleClosed, float64(stats.MaxIdleClosed))
+	counter(dbMaxIdleTimeClosed, float64(stats.MaxIdleTimeClosed))
+	counter(dbMaxLifetimeClosed, float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,75 @@
+// Package metrics defines the server's Prometheus metrics and serves them.
+package metrics
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds only this package's metrics, plus the Go runtime and
+// process collectors, rather than the global default registry.
+var registry = prometheus.NewRegistry()
+
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration_seconds",
+		Help: "HTTP request latency by route.",
+	}, []string{"method", "route", "status"})
+
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deltra_job_runs_total",
+		Help: "Background job runs by result.",
+	}, []string{"job", "result"})
+
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "deltra_job_duration_seconds",
+		Help: "Background job run time.",
+	}, []string{"job"})
+
+	PortfolioEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deltra_portfolio_events_total",
+		Help: "Committed portfolio changes by event type, e.g. call.created or call.assigned.",
+	}, []string{"type"})
+)
+
+func init() {
+	registry.MustRegister(
+		RequestDuration,
+		JobRuns,
+		JobDuration,
+		PortfolioEvents,
+		dbPoolCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics to scrapers presenting METRICS_TOKEN as a
+// bearer token. Without a token configured the endpoint stays closed.
+func Handler() gin.HandlerFunc {
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		slog.Info("METRICS_TOKEN not set, /metrics is disabled")
+		return func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		}
+	}
+
+	serve := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return func(c *gin.Context) {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid metrics token"})
+			return
+		}
+		serve.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveMetrics(t *testing.T, authorization string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/metrics", Handler())
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestHandler(t *testing.T) {
+	JobRuns.WithLabelValues("test_job", "success").Inc()
+
+	t.Run("closed without a token", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "")
+		if got := serveMetrics(t, "").Code; got != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", got, http.StatusNotFound)
+		}
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "scrape-token")
+		if got := serveMetrics(t, "Bearer guess").Code; got != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", got, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("right token", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "scrape-token")
+		recorder := serveMetrics(t, "Bearer scrape-token")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+		}
+		body := recorder.Body.String()
+		for _, want := range []string{
+			`deltra_job_runs_total{job="test_job",result="success"} 1`,
+			"go_goroutines",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("metrics missing %q", want)
+			}
+		}
+	})
+}
@@ -1,16 +1,42 @@
 package middleware
 
 import (
+	"deltra-backend/auth"
+	"deltra-backend/logging"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const principalKey = "principal"
+
+// AuthPrincipal returns the caller authenticated by AuthMiddleware.
+func AuthPrincipal(c *gin.Context) (auth.Principal, bool) {
+	principal, ok := c.Get(principalKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	p, ok := principal.(auth.Principal)
+	return p, ok
+}
+
+// setPrincipal records the authenticated caller, including on the request
+// context so logs written while handling the request name the user.
+func setPrincipal(c *gin.Context, principal auth.Principal) {
+	c.Set(principalKey, principal)
+	c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), principal.UserID))
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -31,48 +57,106 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret == "" {
+		if auth.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, tokenString)
+			return
+		}
+
+		validMethods := auth.ValidMethods()
+		if len(validMethods) == 0 {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server configuration error"})
 			c.Abort()
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		principal, err := auth.ParseAccessToken(tokenString)
 		if err != nil {
-			if err == jwt.ErrTokenExpired {
+			switch {
+			case errors.Is(err, jwt.ErrTokenExpired):
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
-			} else if err == jwt.ErrSignatureInvalid {
+			case errors.Is(err, jwt.ErrTokenSignatureInvalid):
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token signature"})
-			} else {
+			case errors.Is(err, auth.ErrWrongTokenType):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh tokens cannot be used as access tokens"})
+			default:
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			}
 			c.Abort()
 			return
 		}
 
-		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		// Every access token must belong to a session so it can be revoked.
+		// Legacy tokens predate sessions and are only admitted during the
+		// configured legacy window.
+		if principal.Legacy {
+			setPrincipal(c, principal)
+			c.Next()
+			return
+		}
+		if principal.SessionID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not bound to a session"})
 			c.Abort()
 			return
 		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["sub"])
-			c.Set("user_email", claims["email"])
-			c.Set("user_claims", claims)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		if !auth.SessionActive(principal.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked"})
 			c.Abort()
 			return
 		}
 
+		setPrincipal(c, principal)
+
 		c.Next()
 	})
 }
+
+func authenticateAPIKey(c *gin.Context, raw string) {
+	principal, err := auth.AuthenticateAPIKey(raw)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !principal.CanWrite() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is read-only"})
+			c.Abort()
+			return
+		}
+	}
+
+	setPrincipal(c, principal)
+	c.Next()
+}
+
+// RequireSession limits a route to callers signed in interactively. Managing
+// sessions, API keys and memberships changes who can act for the account, so
+// API keys can't do it even with write scope.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := AuthPrincipal(c)
+		if !ok || principal.SessionID == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This action requires signing in; API keys cannot perform it"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelf rejects requests to another user's /users/:id routes. Access to
+// shared data goes through portfolio memberships instead.
+func RequireSelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := AuthPrincipal(c)
+		if !ok || principal.UserID != c.Param("id") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"deltra-backend/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+const changesKey = "changes"
+
+// QueueChange holds a change published during the request until the
+// response shows its transaction committed.
+func QueueChange(c *gin.Context, change events.Change) {
+	changes, _ := c.Get(changesKey)
+	queued, _ := changes.([]events.Change)
+	c.Set(changesKey, append(queued, change))
+}
+
+// BroadcastChanges sends a request's queued changes to live streams once the
+// handler has answered. Handlers respond after their transaction returns, so
+// a successful status means the changes are committed.
+func BroadcastChanges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() >= 400 {
+			return
+		}
+		if changes, ok := c.Get(changesKey); ok {
+			events.Broadcast(changes.([]events.Change)...)
+		}
+	}
+}
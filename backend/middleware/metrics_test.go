@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"deltra-backend/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequestMetricsLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestMetrics())
+	r.GET("/v1/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/users/1", nil),
+		httptest.NewRequest(http.MethodGet, "/v1/users/2", nil),
+		httptest.NewRequest(http.MethodGet, "/random/path", nil),
+		httptest.NewRequest("PROPFIND", "/v1/users/1", nil),
+		httptest.NewRequest("X-MADE-UP", "/v1/users/1", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// GET on the route, GET unmatched, and every odd method as "other".
+	if got := testutil.CollectAndCount(metrics.RequestDuration); got != 3 {
+		t.Errorf("request duration has %d series, want 3", got)
+	}
+	for _, method := range []string{"PROPFIND", "X-MADE-UP"} {
+		if metrics.RequestDuration.DeleteLabelValues(method, "unmatched", "404") {
+			t.Errorf("method %s got its own series", method)
+		}
+	}
+	if !metrics.RequestDuration.DeleteLabelValues("other", "unmatched", "404") {
+		t.Error("unknown methods not recorded as other")
+	}
+}
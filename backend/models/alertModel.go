@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+const (
+	AlertCallInTheMoney     = "call_in_the_money"
+	AlertProfitCaptured     = "profit_captured"
+	AlertExpirationNear     = "expiration_near"
+	AlertBelowAdjustedBasis = "below_adjusted_basis"
+)
+
+// AlertRule is checked by the alerts job against every open call or stock
+// it covers. PortfolioID and StockID narrow the rule; left empty it applies
+// to everything the user can see. Threshold is the percent of max profit
+// for profit_captured and the number of days for expiration_near; zero means
+// the default, so the API never stores it as a chosen value.
+type AlertRule struct {
+	ID          string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      string  `gorm:"type:uuid;index" json:"user_id"`
+	PortfolioID *string `gorm:"type:uuid" json:"portfolio_id,omitempty"`
+	StockID     *string `gorm:"type:uuid" json:"stock_id,omitempty"`
+
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Enabled   bool    `json:"enabled"`
+
+	LastEvaluatedAt *time.Time `json:"last_evaluated_at,omitempty"`
+
+	Events []AlertEvent `gorm:"foreignKey:RuleID;constraint:OnDelete:CASCADE" json:"-"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// AlertEvent records a rule firing for one call or stock. Only one event
+// per rule and subject is open at a time; it is cleared once the condition
+// stops holding, which re-arms the rule for that subject.
+type AlertEvent struct {
+	ID            string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RuleID        string  `gorm:"type:uuid;uniqueIndex:idx_alert_event_open,where:cleared_at IS NULL" json:"rule_id"`
+	Subject       string  `gorm:"uniqueIndex:idx_alert_event_open,where:cleared_at IS NULL" json:"subject"`
+	UserID        string  `gorm:"type:uuid;index" json:"user_id"`
+	PortfolioID   string  `gorm:"type:uuid" json:"portfolio_id"`
+	StockID       string  `gorm:"type:uuid" json:"stock_id"`
+	CoveredCallID *string `gorm:"type:uuid" json:"covered_call_id,omitempty"`
+
+	Type    string  `json:"type"`
+	Symbol  string  `json:"symbol"`
+	Message string  `json:"message"`
+	Value   float64 `json:"value"`
+
+	TriggeredAt time.Time  `json:"triggered_at"`
+	ClearedAt   *time.Time `json:"cleared_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func ValidAlertType(alertType string) bool {
+	switch alertType {
+	case AlertCallInTheMoney, AlertProfitCaptured, AlertExpirationNear, AlertBelowAdjustedBasis:
+		return true
+	}
+	return false
+}
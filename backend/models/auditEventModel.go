@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditRevert = "revert"
+
+	AuditCauseAssignment      = "assignment"
+	AuditCauseCorporateAction = "corporate_action"
+)
+
+// AuditEvent is an append-only record of a change to a portfolio, stock or
+// covered call. UserID is the owner of the changed data and ActorID whoever
+// made the change, which differ on shared portfolios. ActorID is nil for
+// changes made by background jobs. CauseType and CauseID link a change made
+// as a side effect of another to what caused it: the covered call whose
+// assignment delivered shares, or the corporate action that adjusted a
+// position. Unlike RequestID, which clients can set, they're only written by
+// the server.
+type AuditEvent struct {
+	ID            string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID        string    `gorm:"type:uuid;index" json:"user_id"`
+	PortfolioID   string    `gorm:"type:uuid;index" json:"portfolio_id"`
+	ActorID       *string   `gorm:"type:uuid" json:"actor_id"`
+	APIKeyID      *string   `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	RequestID     string    `json:"request_id"`
+	Action        string    `json:"action"`
+	EntityType    string    `gorm:"index:idx_audit_entity" json:"entity_type"`
+	EntityID      string    `gorm:"type:uuid;index:idx_audit_entity" json:"entity_id"`
+	Before        JSON      `json:"before"`
+	After         JSON      `json:"after"`
+	Changes       JSON      `json:"changes"`
+	CauseType     string    `json:"cause_type,omitempty"`
+	CauseID       *string   `gorm:"type:uuid;index" json:"cause_id,omitempty"`
+	RevertedEvent *string   `gorm:"type:uuid" json:"reverted_event,omitempty"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// CalendarFeed lets calendar apps, which can't send an Authorization
+// header, subscribe to a user's expirations by a secret URL.
+type CalendarFeed struct {
+	ID            string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID        string     `gorm:"type:uuid;index" json:"user_id"`
+	TokenHash     string     `gorm:"uniqueIndex" json:"-"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
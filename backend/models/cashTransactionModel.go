@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type CashTransaction struct {
+	ID            string  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PortfolioID   string  `gorm:"type:uuid;index" json:"portfolio_id"`
+	UserID        string  `gorm:"type:uuid" json:"user_id"`
+	StockID       *string `gorm:"type:uuid" json:"stock_id,omitempty"`
+	CoveredCallID *string `gorm:"type:uuid" json:"covered_call_id,omitempty"`
+
+	Type        string    `json:"type"`
+	Amount      float64   `json:"amount"`
+	Description string    `json:"description,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// IsExternal reports whether the transaction moved money into or out of the
+// account, as opposed to trading activity within it.
+func (t *CashTransaction) IsExternal() bool {
+	return t.Type == "deposit" || t.Type == "withdrawal"
+}
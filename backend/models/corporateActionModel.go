@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+type CorporateAction struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      string `gorm:"type:uuid" json:"user_id"`
+	StockID     string `gorm:"type:uuid" json:"stock_id"`
+	PortfolioID string `gorm:"type:uuid" json:"portfolio_id"`
+
+	Type          string    `json:"type"`
+	EffectiveDate time.Time `json:"effective_date"`
+
+	// RatioTo new shares are received for every RatioFrom shares held. For a
+	// spin-off the new shares are of SpinOffSymbol.
+	RatioFrom float64 `json:"ratio_from,omitempty"`
+	RatioTo   float64 `json:"ratio_to,omitempty"`
+
+	NewSymbol           string  `json:"new_symbol,omitempty"`
+	SpinOffSymbol       string  `json:"spin_off_symbol,omitempty"`
+	SpinOffBasisPercent float64 `json:"spin_off_basis_percent,omitempty"`
+	CashAmount          float64 `json:"cash_amount,omitempty"`
+
+	Status    string     `json:"status"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+
+	Adjustments []CorporateActionAdjustment `gorm:"foreignKey:CorporateActionID;constraint:OnDelete:CASCADE" json:"adjustments,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type CorporateActionAdjustment struct {
+	ID                string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	CorporateActionID string    `gorm:"type:uuid;index" json:"corporate_action_id"`
+	EntityType        string    `json:"entity_type"`
+	EntityID          string    `gorm:"type:uuid" json:"entity_id"`
+	Before            JSON      `json:"before"`
+	After             JSON      `json:"after"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (a *CorporateAction) Ratio() float64 {
+	if a.RatioFrom == 0 {
+		return 0
+	}
+	return a.RatioTo / a.RatioFrom
+}
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type CoveredCall struct {
 	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
@@ -11,6 +16,7 @@ type CoveredCall struct {
 	StrikePrice     float64   `json:"strike_price"`
 	PremiumReceived float64   `json:"premium_received"`
 	Contracts       int       `json:"contracts"`
+	Deliverable     int       `gorm:"default:100" json:"deliverable"`
 	ExpirationDate  time.Time `json:"expiration_date"`
 
 	Status string `json:"status"`
@@ -22,12 +28,54 @@ type CoveredCall struct {
 
 	TotalPremium  float64 `json:"total_premium"`
 	SharesCovered int     `json:"shares_covered"`
+	// SharesDelivered is set once assignment has taken the covered shares
+	// out of the stock position.
+	SharesDelivered bool `gorm:"not null;default:false" json:"shares_delivered"`
+
+	OpenFees       float64 `json:"open_fees"`
+	BuybackFees    float64 `json:"buyback_fees"`
+	AssignmentFees float64 `json:"assignment_fees"`
+	NetPremium     float64 `gorm:"-" json:"net_premium"`
+
+	EarlyAssignmentRisk bool `gorm:"-" json:"early_assignment_risk"`
 
 	Stock     Stock     `gorm:"foreignKey:StockID" json:"stock"`
 	Portfolio Portfolio `gorm:"foreignKey:PortfolioID" json:"portfolio"`
 	User      User      `gorm:"foreignKey:UserID" json:"user"`
 
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// AdjustForSplit follows the OCC convention: whole-number splits multiply the
+// contract count, anything else changes the shares delivered per contract.
+func (c *CoveredCall) AdjustForSplit(ratio float64) {
+	if ratio == math.Trunc(ratio) {
+		c.Contracts *= int(ratio)
+		c.PremiumReceived /= ratio
+	} else {
+		c.Deliverable = int(math.Round(float64(c.Deliverable) * ratio))
+	}
+	c.StrikePrice /= ratio
+	c.SharesCovered = c.Contracts * c.Deliverable
 }
 
+func (c *CoveredCall) AfterFind(tx *gorm.DB) error {
+	c.CalculateNetPremium()
+	return nil
+}
+
+func (c *CoveredCall) CalculateNetPremium() {
+	c.NetPremium = c.TotalPremium - c.OpenFees - c.BuybackFees - c.AssignmentFees - c.BuybackCost()
+}
+
+// BuybackCost is what closing the call cost before fees. The premium is per
+// share, so it applies to every share covered, including after splits that
+// changed the deliverable.
+func (c *CoveredCall) BuybackCost() float64 {
+	if c.BuybackPremium == nil {
+		return 0
+	}
+	return *c.BuybackPremium * float64(c.SharesCovered)
+}
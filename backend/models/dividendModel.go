@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const EarlyAssignmentWindow = 14 * 24 * time.Hour
+
+type Dividend struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      string `gorm:"type:uuid" json:"user_id"`
+	StockID     string `gorm:"type:uuid;index" json:"stock_id"`
+	PortfolioID string `gorm:"type:uuid" json:"portfolio_id"`
+
+	ExDate         time.Time  `json:"ex_date"`
+	PayDate        *time.Time `json:"pay_date,omitempty"`
+	AmountPerShare float64    `json:"amount_per_share"`
+	Shares         float64    `json:"shares"`
+	TotalAmount    float64    `json:"total_amount"`
+	Qualified      bool       `json:"qualified"`
+
+	ReinvestedShares float64 `json:"reinvested_shares"`
+	// ReinvestedAt is set once the reinvested shares are added to the
+	// position, which waits for the pay date.
+	ReinvestedAt *time.Time `json:"reinvested_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ReinvestmentDue reports whether the dividend's reinvested shares should now
+// be added to the position. Without a pay date they are added right away.
+func (d *Dividend) ReinvestmentDue(now time.Time) bool {
+	if d.ReinvestedShares <= 0 || d.ReinvestedAt != nil {
+		return false
+	}
+	return d.PayDate == nil || !d.PayDate.After(now)
+}
+
+// Reinvest adds the reinvested shares to the stock, folding the dividend into
+// its cost basis.
+func (d *Dividend) Reinvest(stock *Stock, now time.Time) {
+	totalCost := stock.Basis*stock.Shares + d.TotalAmount
+	stock.Shares += d.ReinvestedShares
+	stock.Basis = totalCost / stock.Shares
+	d.ReinvestedAt = &now
+}
+
+// Unreinvest takes back shares added by Reinvest.
+func (d *Dividend) Unreinvest(stock *Stock) {
+	remaining := stock.Shares - d.ReinvestedShares
+	if remaining > 0 {
+		stock.Basis = (stock.Basis*stock.Shares - d.TotalAmount) / remaining
+	}
+	stock.Shares = remaining
+	d.ReinvestedAt = nil
+}
+
+// AtRiskOfEarlyAssignment reports whether an active call is likely to be
+// exercised early to capture the dividend: the ex-date is coming up soon and
+// falls before the call expires.
+func (d *Dividend) AtRiskOfEarlyAssignment(call CoveredCall, now time.Time) bool {
+	if call.Status != "active" || !d.ExDate.After(now) {
+		return false
+	}
+	return d.ExDate.Sub(now) <= EarlyAssignmentWindow && !d.ExDate.After(call.ExpirationDate)
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type FeeSchedule struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PortfolioID string `gorm:"type:uuid;uniqueIndex" json:"portfolio_id"`
+	UserID      string `gorm:"type:uuid" json:"user_id"`
+
+	StockTradeFee  float64 `json:"stock_trade_fee"`
+	PerShareFee    float64 `json:"per_share_fee"`
+	OptionTradeFee float64 `json:"option_trade_fee"`
+	PerContractFee float64 `json:"per_contract_fee"`
+	AssignmentFee  float64 `json:"assignment_fee"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (f *FeeSchedule) StockTrade(shares float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return f.StockTradeFee + f.PerShareFee*shares
+}
+
+func (f *FeeSchedule) OptionTrade(contracts int) float64 {
+	if f == nil {
+		return 0
+	}
+	return f.OptionTradeFee + f.PerContractFee*float64(contracts)
+}
+
+func (f *FeeSchedule) Assignment() float64 {
+	if f == nil {
+		return 0
+	}
+	return f.AssignmentFee
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Identity links a sign-in provider account to a user. A user can have one
+// identity per provider account, e.g. both Google and Apple.
+type Identity struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID     string    `gorm:"type:uuid;index" json:"user_id"`
+	Provider   string    `gorm:"uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject    string    `gorm:"uniqueIndex:idx_identity_provider_subject" json:"-"`
+	Email      string    `json:"email"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+const (
+	ChannelPush    = "push"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Notification is one message to a user, fanned out to a delivery per
+// channel and device.
+type Notification struct {
+	ID     string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID string `gorm:"type:uuid;index" json:"user_id"`
+	Kind   string `json:"kind"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Data   JSON   `json:"data,omitempty"`
+
+	ReadAt *time.Time `json:"read_at,omitempty"`
+
+	Deliveries []NotificationDelivery `gorm:"foreignKey:NotificationID;constraint:OnDelete:CASCADE" json:"deliveries,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// NotificationDelivery tracks sending a notification over one channel to
+// one target: an Expo push token, an email address or a webhook URL.
+type NotificationDelivery struct {
+	ID             string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	NotificationID string `gorm:"type:uuid;index" json:"notification_id"`
+	UserID         string `gorm:"type:uuid;index" json:"user_id"`
+	Channel        string `json:"channel"`
+	Target         string `json:"-"`
+
+	Status        string     `gorm:"index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PushDevice is an Expo push token registered by one of the user's devices.
+type PushDevice struct {
+	ID         string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID     string    `gorm:"type:uuid;index" json:"user_id"`
+	Token      string    `gorm:"uniqueIndex" json:"token"`
+	Platform   string    `json:"platform,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// NotificationWebhook is where a user's webhook channel posts. Payloads
+// are signed with Secret, which is only shown when the webhook is set.
+type NotificationWebhook struct {
+	ID        string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID    string    `gorm:"type:uuid;uniqueIndex" json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func ValidChannel(channel string) bool {
+	return channel == ChannelPush || channel == ChannelEmail || channel == ChannelWebhook
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+
+	MembershipPending  = "pending"
+	MembershipAccepted = "accepted"
+	MembershipDeclined = "declined"
+)
+
+// PortfolioMember grants a user access to a portfolio. Invitations are
+// addressed by email and have no UserID until they are accepted.
+type PortfolioMember struct {
+	ID          string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PortfolioID string     `gorm:"type:uuid;uniqueIndex:idx_portfolio_member_email" json:"portfolio_id"`
+	UserID      *string    `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Email       string     `gorm:"uniqueIndex:idx_portfolio_member_email" json:"email"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	InvitedBy   *string    `gorm:"type:uuid" json:"invited_by,omitempty"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Portfolio *Portfolio `gorm:"foreignKey:PortfolioID" json:"portfolio,omitempty"`
+}
+
+func ValidMemberRole(role string) bool {
+	return role == RoleEditor || role == RoleViewer
+}
@@ -1,13 +1,25 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Portfolio struct {
-	ID        string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Name      string    `json:"name"`
-	UserID    string    `gorm:"type:uuid" json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Stocks    []Stock   `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"stocks,omitempty"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          string            `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name        string            `json:"name"`
+	EnforceCash bool              `json:"enforce_cash"`
+	UserID      string            `gorm:"type:uuid" json:"user_id"`
+	User        User              `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Stocks      []Stock           `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"stocks,omitempty"`
+	FeeSchedule *FeeSchedule      `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"fee_schedule,omitempty"`
+	Members     []PortfolioMember `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"members,omitempty"`
+	ShareLinks  []ShareLink       `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt    `gorm:"index" json:"deleted_at"`
+
+	CashBalance float64 `gorm:"-" json:"cash_balance"`
+	Role        string  `gorm:"-" json:"role,omitempty"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ShareLink publishes a read-only view of a portfolio to anyone holding the
+// token. HideAmounts redacts share counts and dollar figures, leaving
+// symbols, weights and yields.
+type ShareLink struct {
+	ID           string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PortfolioID  string     `gorm:"type:uuid;index" json:"portfolio_id"`
+	UserID       string     `gorm:"type:uuid" json:"user_id"`
+	TokenHash    string     `gorm:"uniqueIndex" json:"-"`
+	HideAmounts  bool       `json:"hide_amounts"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ViewCount    int        `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (l *ShareLink) Active(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type PortfolioSnapshot struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	PortfolioID string    `gorm:"type:uuid;uniqueIndex:idx_portfolio_snapshot_date" json:"portfolio_id"`
+	UserID      string    `gorm:"type:uuid" json:"user_id"`
+	Date        time.Time `gorm:"type:date;uniqueIndex:idx_portfolio_snapshot_date" json:"date"`
+
+	Cash              float64 `json:"cash"`
+	StockValue        float64 `json:"stock_value"`
+	OpenCallLiability float64 `json:"open_call_liability"`
+	CumulativePremium float64 `json:"cumulative_premium"`
+	TotalValue        float64 `json:"total_value"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type StockSnapshot struct {
+	ID          string    `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	StockID     string    `gorm:"type:uuid;uniqueIndex:idx_stock_snapshot_date" json:"stock_id"`
+	PortfolioID string    `gorm:"type:uuid;index" json:"portfolio_id"`
+	UserID      string    `gorm:"type:uuid" json:"user_id"`
+	Date        time.Time `gorm:"type:date;uniqueIndex:idx_stock_snapshot_date" json:"date"`
+
+	Symbol            string  `json:"symbol"`
+	Shares            float64 `json:"shares"`
+	Price             float64 `json:"price"`
+	MarketValue       float64 `json:"market_value"`
+	OpenCallLiability float64 `json:"open_call_liability"`
+	CumulativePremium float64 `json:"cumulative_premium"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -1,40 +1,80 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Stock struct {
-	ID           string        `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	UserID       string        `gorm:"type:uuid" json:"user_id"`
-	PortfolioID  string        `gorm:"type:uuid" json:"portfolio_id"`
-	Symbol       string        `json:"symbol"`
-	Basis        float64       `json:"basis"`
-	Shares       float64       `json:"shares"`
-	Portfolio    Portfolio     `gorm:"foreignKey:PortfolioID" json:"portfolio,omitempty"`
-	User         User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	CoveredCalls []CoveredCall `gorm:"foreignKey:StockID;constraint:OnDelete:CASCADE" json:"covered_calls,omitempty"`
-	CreatedAt    time.Time     `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
-
-	AdjustedBasis    float64 `gorm:"-" json:"adjusted_basis"`
-	TotalPremium     float64 `gorm:"-" json:"total_premium"`
-	ActiveCalls      int     `gorm:"-" json:"active_calls"`
-	SharesCovered    int     `gorm:"-" json:"shares_covered"`
-	SharesAvailable  int     `gorm:"-" json:"shares_available"`
+	ID                   string         `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID               string         `gorm:"type:uuid" json:"user_id"`
+	PortfolioID          string         `gorm:"type:uuid" json:"portfolio_id"`
+	Symbol               string         `json:"symbol"`
+	Basis                float64        `json:"basis"`
+	Shares               float64        `json:"shares"`
+	PurchaseFees         float64        `json:"purchase_fees"`
+	DividendsReduceBasis bool           `json:"dividends_reduce_basis"`
+	Portfolio            Portfolio      `gorm:"foreignKey:PortfolioID" json:"portfolio,omitempty"`
+	User                 User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CoveredCalls         []CoveredCall  `gorm:"foreignKey:StockID;constraint:OnDelete:CASCADE" json:"covered_calls,omitempty"`
+	Dividends            []Dividend     `gorm:"foreignKey:StockID;constraint:OnDelete:CASCADE" json:"dividends,omitempty"`
+	CreatedAt            time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	AdjustedBasis   float64 `gorm:"-" json:"adjusted_basis"`
+	TotalPremium    float64 `gorm:"-" json:"total_premium"`
+	NetPremium      float64 `gorm:"-" json:"net_premium"`
+	ActiveCalls     int     `gorm:"-" json:"active_calls"`
+	SharesCovered   int     `gorm:"-" json:"shares_covered"`
+	SharesAvailable int     `gorm:"-" json:"shares_available"`
+	TotalDividends  float64 `gorm:"-" json:"total_dividends"`
 }
 
 func (s *Stock) CalculateMetrics() {
 	s.TotalPremium = 0
+	s.NetPremium = 0
 	s.ActiveCalls = 0
 	s.SharesCovered = 0
+	s.TotalDividends = 0
+
+	now := time.Now()
+
+	for _, dividend := range s.Dividends {
+		if !dividend.ExDate.After(now) {
+			s.TotalDividends += dividend.TotalAmount
+		}
+	}
 
-	for _, call := range s.CoveredCalls {
+	for i, call := range s.CoveredCalls {
+		call.CalculateNetPremium()
 		s.TotalPremium += call.TotalPremium
+		s.NetPremium += call.NetPremium
 		if call.Status == "active" {
 			s.ActiveCalls++
 			s.SharesCovered += call.SharesCovered
 		}
+		for _, dividend := range s.Dividends {
+			if dividend.AtRiskOfEarlyAssignment(call, now) {
+				s.CoveredCalls[i].EarlyAssignmentRisk = true
+			}
+		}
+	}
+
+	reduction := s.NetPremium - s.PurchaseFees
+	if s.DividendsReduceBasis {
+		reduction += s.TotalDividends
 	}
 
 	s.SharesAvailable = int(s.Shares) - s.SharesCovered
-	s.AdjustedBasis = s.Basis - (s.TotalPremium / s.Shares)
+	s.AdjustedBasis = s.Basis
+	if s.Shares > 0 {
+		s.AdjustedBasis -= reduction / s.Shares
+	}
+}
+
+func (s *Stock) AdjustForSplit(ratio float64) {
+	s.Shares *= ratio
+	s.Basis /= ratio
 }
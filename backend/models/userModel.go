@@ -3,12 +3,40 @@ package models
 import "time"
 
 type User struct {
-	ID         string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	Name       string `json:"name"`
-	Email      string `gorm:"unique" json:"email"`
-	Provider   string `gorm:"uniqueIndex:idx_provider_user" json:"provider"`
-	ProviderID string `gorm:"uniqueIndex:idx_provider_user" json:"provider_id"`
-	Picture    string `json:"picture"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          string          `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Name        string          `json:"name"`
+	Email       string          `gorm:"unique" json:"email"`
+	Picture     string          `json:"picture"`
+	Preferences UserPreferences `gorm:"type:jsonb;serializer:json" json:"preferences"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// EmailVerified is set once a provider has vouched for Email. Portfolio
+	// invitations are only matched against verified emails.
+	EmailVerified bool `gorm:"not null;default:false" json:"email_verified"`
+
+	Identities []Identity `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"identities,omitempty"`
+}
+
+type UserPreferences struct {
+	Currency           string `json:"currency,omitempty"`
+	Timezone           string `json:"timezone,omitempty"`
+	Benchmark          string `json:"benchmark,omitempty"`
+	DefaultPortfolioID string `json:"default_portfolio_id,omitempty"`
+
+	// NotificationChannels lists the channels notifications go out on. Nil
+	// means every channel the user has set up.
+	NotificationChannels []string `json:"notification_channels,omitempty"`
+}
+
+func (p UserPreferences) NotifiesOn(channel string) bool {
+	if p.NotificationChannels == nil {
+		return true
+	}
+	for _, enabled := range p.NotificationChannels {
+		if enabled == channel {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// WebhookEndpoint receives portfolio events for every portfolio its owner
+// is a member of. EventTypes may include "*" to receive everything.
+type WebhookEndpoint struct {
+	ID          string   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID      string   `gorm:"type:uuid;index" json:"user_id"`
+	URL         string   `json:"url"`
+	Description string   `json:"description,omitempty"`
+	EventTypes  []string `gorm:"type:jsonb;serializer:json" json:"event_types"`
+	Secret      string   `json:"-"`
+	Enabled     bool     `json:"enabled"`
+
+	Deliveries []WebhookDelivery `gorm:"foreignKey:EndpointID;constraint:OnDelete:CASCADE" json:"-"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookDelivery is the log of sending one event to one endpoint.
+// Replaying an event adds a new delivery with the same payload.
+type WebhookDelivery struct {
+	ID         string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	EndpointID string `gorm:"type:uuid;index" json:"endpoint_id"`
+	UserID     string `gorm:"type:uuid;index" json:"user_id"`
+	EventID    string `gorm:"type:uuid" json:"event_id"`
+	EventType  string `json:"event_type"`
+	Payload    JSON   `json:"payload"`
+
+	Status         string     `gorm:"index" json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DurationMs     int64      `json:"duration_ms,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	ReplayOf       *string    `gorm:"type:uuid" json:"replay_of,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (e *WebhookEndpoint) Subscribed(eventType string) bool {
+	for _, subscribed := range e.EventTypes {
+		if subscribed == "*" || subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned when a webhook URL leads to an address
+// outside the public internet, such as loopback or the cloud metadata
+// endpoint.
+var ErrPrivateAddress = errors.New("webhook address is not publicly routable")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), which netip doesn't
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// AllowPrivateNetworks reports whether webhooks may reach private and
+// loopback addresses, from WEBHOOK_ALLOW_PRIVATE_NETWORKS. Only for local
+// development, where receivers run on localhost.
+func AllowPrivateNetworks() bool {
+	return os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+}
+
+// PublicAddress reports whether addr is on the public internet.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// NewWebhookClient returns a client for user-supplied URLs. Addresses are
+// checked after DNS resolution, on the IP actually dialed, so a hostname
+// can't be pointed inward. Redirects aren't followed: a 3xx is returned as
+// the response and counts as a failed delivery.
+func NewWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !PublicAddress(addr) && !AllowPrivateNetworks() {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addr)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// A proxy would make the dial, so it's never used here.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const expoPushURL = "https://exp.host/--/api/v2/push/send"
+
+// Expo sends push notifications through Expo's push service.
+type Expo struct {
+	accessToken string
+	client      *http.Client
+}
+
+type expoMessage struct {
+	To    string      `json:"to"`
+	Title string      `json:"title"`
+	Body  string      `json:"body"`
+	Data  models.JSON `json:"data,omitempty"`
+	Sound string      `json:"sound"`
+}
+
+type expoResponse struct {
+	Data struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details struct {
+			Error string `json:"error"`
+		} `json:"details"`
+	} `json:"data"`
+}
+
+func NewExpo(accessToken string) *Expo {
+	return &Expo{
+		accessToken: accessToken,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *Expo) Send(ctx context.Context, delivery models.NotificationDelivery, notification models.Notification) error {
+	body, err := json.Marshal(expoMessage{
+		To:    delivery.Target,
+		Title: notification.Title,
+		Body:  notification.Body,
+		Data:  notification.Data,
+		Sound: "default",
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, expoPushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if e.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+e.accessToken)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expo push failed: %s", resp.Status)
+	}
+
+	var result expoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Data.Status == "ok" {
+		return nil
+	}
+
+	// The app was uninstalled or the token revoked; stop sending to it.
+	if result.Data.Details.Error == "DeviceNotRegistered" {
+		config.DB.Where("token = ?", delivery.Target).Delete(&models.PushDevice{})
+		return Permanent(errors.New("device not registered"))
+	}
+	return fmt.Errorf("expo push failed: %s", result.Data.Message)
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"deltra-backend/models"
+	"log/slog"
+	"sync"
+)
+
+// FakeTransport records deliveries instead of sending them, for local
+// development and tests. Set Err to make every send fail.
+type FakeTransport struct {
+	Err error
+
+	mu   sync.Mutex
+	sent []FakeDelivery
+}
+
+type FakeDelivery struct {
+	Channel string
+	Target  string
+	Kind    string
+	Title   string
+	Body    string
+}
+
+func NewFakeTransport() *FakeTransport {
+	return &FakeTransport{}
+}
+
+func (f *FakeTransport) Send(ctx context.Context, delivery models.NotificationDelivery, notification models.Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		return f.Err
+	}
+
+	f.sent = append(f.sent, FakeDelivery{
+		Channel: delivery.Channel,
+		Target:  delivery.Target,
+		Kind:    notification.Kind,
+		Title:   notification.Title,
+		Body:    notification.Body,
+	})
+	slog.InfoContext(ctx, "Notification", "kind", notification.Kind, "channel", delivery.Channel, "title", notification.Title)
+	return nil
+}
+
+// Sent returns a copy of everything delivered so far.
+func (f *FakeTransport) Sent() []FakeDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeDelivery(nil), f.sent...)
+}
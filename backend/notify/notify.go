@@ -0,0 +1,251 @@
+// Package notify sends notifications to users over push, email and webhook
+// channels. Enqueue records a notification with one delivery per target
+// inside the caller's transaction; DeliverPending, run by the jobs package,
+// sends them and retries failures with exponential backoff.
+package notify
+
+import (
+	"context"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxAttempts  = 6
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = time.Hour
+	deliverBatch = 100
+
+	// claimLease has to outlast sending a whole batch, one timeout each.
+	claimLease = 20 * time.Minute
+)
+
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+	Data  map[string]any
+}
+
+// Transport delivers a notification to one target on its channel.
+type Transport interface {
+	Send(ctx context.Context, delivery models.NotificationDelivery, notification models.Notification) error
+}
+
+// PermanentError marks a failure retrying can't fix, such as an
+// unregistered push token.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// transports is filled in by Init or Use at startup, before any
+// notifications are sent.
+var transports = map[string]Transport{}
+
+// Init sets up transports from the environment:
+//
+//	NOTIFY_TRANSPORT   "fake" records every channel in memory and logs it
+//	EXPO_ACCESS_TOKEN  optional, for Expo projects with enhanced security
+//	SMTP_HOST          enables email, with SMTP_PORT, SMTP_USERNAME,
+//	                   SMTP_PASSWORD and SMTP_FROM
+//
+// Push and webhooks are always on.
+func Init() {
+	if os.Getenv("NOTIFY_TRANSPORT") == "fake" {
+		fake := NewFakeTransport()
+		for _, channel := range []string{models.ChannelPush, models.ChannelEmail, models.ChannelWebhook} {
+			Use(channel, fake)
+		}
+		slog.Info("NOTIFY_TRANSPORT=fake, notifications are logged instead of sent")
+		return
+	}
+
+	Use(models.ChannelPush, NewExpo(os.Getenv("EXPO_ACCESS_TOKEN")))
+	Use(models.ChannelWebhook, NewWebhook())
+
+	if smtp, err := SMTPFromEnv(); err != nil {
+		slog.Info("Email notifications disabled", "reason", err)
+	} else {
+		Use(models.ChannelEmail, smtp)
+	}
+}
+
+// Use registers the transport for a channel, replacing any before it.
+func Use(channel string, transport Transport) {
+	transports[channel] = transport
+}
+
+func Enabled(channel string) bool {
+	return transports[channel] != nil
+}
+
+// Enqueue stores a notification for userID and queues a delivery for each
+// channel the user has enabled and set up.
+func Enqueue(tx *gorm.DB, userID string, message Message) (models.Notification, error) {
+	var user models.User
+	if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+		return models.Notification{}, err
+	}
+
+	data, err := models.NewJSON(message.Data)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	notification := models.Notification{
+		UserID: userID,
+		Kind:   message.Kind,
+		Title:  message.Title,
+		Body:   message.Body,
+		Data:   data,
+	}
+	if err := tx.Create(&notification).Error; err != nil {
+		return notification, err
+	}
+
+	var targets []models.NotificationDelivery
+	addTarget := func(channel, target string) {
+		targets = append(targets, models.NotificationDelivery{
+			NotificationID: notification.ID,
+			UserID:         userID,
+			Channel:        channel,
+			Target:         target,
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  notification.CreatedAt,
+		})
+	}
+
+	if wants(user, models.ChannelPush) {
+		var devices []models.PushDevice
+		if err := tx.Where("user_id = ?", userID).Find(&devices).Error; err != nil {
+			return notification, err
+		}
+		for _, device := range devices {
+			addTarget(models.ChannelPush, device.Token)
+		}
+	}
+	if wants(user, models.ChannelEmail) && user.Email != "" {
+		addTarget(models.ChannelEmail, user.Email)
+	}
+	if wants(user, models.ChannelWebhook) {
+		var webhook models.NotificationWebhook
+		err := tx.Where("user_id = ?", userID).First(&webhook).Error
+		if err == nil {
+			addTarget(models.ChannelWebhook, webhook.URL)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return notification, err
+		}
+	}
+
+	if len(targets) > 0 {
+		if err := tx.Create(&targets).Error; err != nil {
+			return notification, err
+		}
+	}
+	notification.Deliveries = targets
+	return notification, nil
+}
+
+func wants(user models.User, channel string) bool {
+	return Enabled(channel) && user.Preferences.NotifiesOn(channel)
+}
+
+// ClaimDue claims up to limit due rows of model in a short transaction,
+// pushing their next attempt out by lease so no other instance picks them
+// up while they're sent. Rows are locked with SKIP LOCKED so several
+// instances can claim at once; a crash mid-send leaves rows to be retried
+// once the lease runs out.
+func ClaimDue(ctx context.Context, dest any, limit int, lease time.Duration) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(dest)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(dest).Update("next_attempt_at", now.Add(lease)).Error
+	})
+}
+
+// DeliverPending sends deliveries that are due. They're claimed first and
+// sent outside any transaction, and each result is written on its own.
+func DeliverPending(ctx context.Context) error {
+	var deliveries []models.NotificationDelivery
+	if err := ClaimDue(ctx, &deliveries, deliverBatch, claimLease); err != nil {
+		return err
+	}
+
+	db := config.DB.WithContext(context.WithoutCancel(ctx))
+	var errs []error
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			// Unsent claims are retried once their lease runs out.
+			return errors.Join(append(errs, err)...)
+		}
+
+		var notification models.Notification
+		if err := db.Where("id = ?", delivery.NotificationID).First(&notification).Error; err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		sendErr := errors.New("no transport for channel " + delivery.Channel)
+		if transport := transports[delivery.Channel]; transport != nil {
+			sendErr = transport.Send(ctx, delivery, notification)
+		}
+		recordAttempt(&delivery, sendErr, time.Now())
+
+		if err := db.Model(&delivery).
+			Select("status", "attempts", "next_attempt_at", "last_error", "sent_at").
+			Updates(&delivery).Error; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func recordAttempt(delivery *models.NotificationDelivery, err error, now time.Time) {
+	delivery.Attempts++
+
+	if err == nil {
+		delivery.Status = models.DeliverySent
+		delivery.SentAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	var permanent *PermanentError
+	if errors.As(err, &permanent) || delivery.Attempts >= MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+}
+
+// Backoff is the wait after the given number of failed attempts: 30s,
+// 1m, 2m, ... capped at an hour.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff << (attempts - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
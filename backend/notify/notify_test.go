@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"context"
+	"deltra-backend/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.5", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := PublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("PublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostSignedRefusesPrivateAddresses(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "")
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	_, err := PostSigned(context.Background(), NewWebhookClient(), server.URL, "secret", "test", "delivery-1", []byte("{}"))
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("PostSigned() error = %v, want %v", err, ErrPrivateAddress)
+	}
+	var permanent *PermanentError
+	if !errors.As(err, &permanent) {
+		t.Error("private address should fail permanently")
+	}
+	if hit {
+		t.Error("request reached the loopback server")
+	}
+}
+
+func TestPostSigned(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+
+	var received *http.Request
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hook", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewWebhookClient()
+
+	t.Run("signed", func(t *testing.T) {
+		status, err := PostSigned(context.Background(), client, server.URL+"/hook", "secret", "notification.alert", "delivery-1", []byte(`{"a":1}`))
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("PostSigned() = %d, %v", status, err)
+		}
+		if received.Header.Get(EventHeader) != "notification.alert" || received.Header.Get(DeliveryHeader) != "delivery-1" {
+			t.Errorf("missing event headers: %v", received.Header)
+		}
+		signature := received.Header.Get(SignatureHeader)
+		if !strings.HasPrefix(signature, "t=") || !strings.Contains(signature, ",v1=") {
+			t.Errorf("malformed signature %q", signature)
+		}
+	})
+
+	t.Run("redirects are not followed", func(t *testing.T) {
+		received = nil
+		status, err := PostSigned(context.Background(), client, server.URL+"/moved", "secret", "test", "delivery-2", []byte("{}"))
+		if err == nil || status != http.StatusTemporaryRedirect {
+			t.Fatalf("PostSigned() = %d, %v, want a failed 307", status, err)
+		}
+		if received != nil {
+			t.Error("redirect was followed")
+		}
+	})
+
+	t.Run("gone is permanent", func(t *testing.T) {
+		_, err := PostSigned(context.Background(), client, server.URL+"/gone", "secret", "test", "delivery-3", []byte("{}"))
+		var permanent *PermanentError
+		if !errors.As(err, &permanent) {
+			t.Fatalf("PostSigned() error = %v, want a permanent error", err)
+		}
+	})
+}
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	got := Sign("whsec_test", timestamp, []byte(`{"id":"1"}`))
+	if got != Sign("whsec_test", timestamp, []byte(`{"id":"1"}`)) {
+		t.Fatal("Sign is not deterministic")
+	}
+	if got == Sign("whsec_other", timestamp, []byte(`{"id":"1"}`)) {
+		t.Error("signature does not depend on the secret")
+	}
+	if !strings.HasPrefix(got, "t=1700000000,v1=") {
+		t.Errorf("Sign() = %q", got)
+	}
+}
+
+func TestRecordAttempt(t *testing.T) {
+	now := time.Now()
+	transient := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		attempts    int
+		err         error
+		wantStatus  string
+		wantBackoff time.Duration
+	}{
+		{name: "sent", err: nil, wantStatus: models.DeliverySent},
+		{name: "first failure backs off", err: transient, wantStatus: models.DeliveryPending, wantBackoff: 30 * time.Second},
+		{name: "third failure backs off longer", attempts: 2, err: transient, wantStatus: models.DeliveryPending, wantBackoff: 2 * time.Minute},
+		{name: "permanent failure", err: Permanent(transient), wantStatus: models.DeliveryFailed},
+		{name: "out of attempts", attempts: MaxAttempts - 1, err: transient, wantStatus: models.DeliveryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := models.NotificationDelivery{Status: models.DeliveryPending, Attempts: tt.attempts}
+			recordAttempt(&delivery, tt.err, now)
+
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", delivery.Status, tt.wantStatus)
+			}
+			if delivery.Attempts != tt.attempts+1 {
+				t.Errorf("Attempts = %d, want %d", delivery.Attempts, tt.attempts+1)
+			}
+			if tt.wantBackoff != 0 && !delivery.NextAttemptAt.Equal(now.Add(tt.wantBackoff)) {
+				t.Errorf("NextAttemptAt = %v, want %v", delivery.NextAttemptAt, now.Add(tt.wantBackoff))
+			}
+			if tt.err == nil && (delivery.SentAt == nil || delivery.LastError != "") {
+				t.Errorf("sent delivery not marked sent: %+v", delivery)
+			}
+			if tt.err != nil && delivery.LastError == "" {
+				t.Error("LastError not recorded")
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestFakeTransport(t *testing.T) {
+	fake := NewFakeTransport()
+	delivery := models.NotificationDelivery{Channel: models.ChannelPush, Target: "ExponentPushToken[abc]"}
+	notification := models.Notification{Kind: "alert", Title: "AAPL alert", Body: "In the money"}
+
+	if err := fake.Send(context.Background(), delivery, notification); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.Sent()
+	want := FakeDelivery{Channel: models.ChannelPush, Target: "ExponentPushToken[abc]", Kind: "alert", Title: "AAPL alert", Body: "In the money"}
+	if len(sent) != 1 || sent[0] != want {
+		t.Fatalf("Sent() = %+v, want [%+v]", sent, want)
+	}
+
+	sent[0].Title = "changed"
+	if fake.Sent()[0].Title != "AAPL alert" {
+		t.Error("Sent() exposed the transport's own slice")
+	}
+
+	fake.Err = Permanent(errors.New("unregistered"))
+	err := fake.Send(context.Background(), delivery, notification)
+	if !errors.Is(err, fake.Err) {
+		t.Fatalf("Send() error = %v, want %v", err, fake.Err)
+	}
+	if len(fake.Sent()) != 1 {
+		t.Error("failed send was recorded")
+	}
+}
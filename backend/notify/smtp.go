@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"deltra-backend/models"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTP sends email notifications as plain text.
+type SMTP struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	envelope string
+}
+
+func SMTPFromEnv() (*SMTP, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, errors.New("SMTP_HOST not set")
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		return nil, errors.New("SMTP_FROM not set")
+	}
+
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	sender := &SMTP{addr: net.JoinHostPort(host, port), from: address.String(), envelope: address.Address}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		sender.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return sender, nil
+}
+
+func (s *SMTP) Send(ctx context.Context, delivery models.NotificationDelivery, notification models.Notification) error {
+	if strings.ContainsAny(delivery.Target, "\r\n") {
+		return Permanent(errors.New("invalid email address"))
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", delivery.Target)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	// net/smtp has no context support, so a cancelled job finishes the
+	// message it is sending.
+	return smtp.SendMail(s.addr, s.auth, s.envelope, []string{delivery.Target}, []byte(msg.String()))
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"deltra-backend/config"
+	"deltra-backend/models"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Deltra-Signature"
+	EventHeader     = "X-Deltra-Event"
+	DeliveryHeader  = "X-Deltra-Delivery"
+)
+
+// Sign returns the signature header value for a webhook body:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers should
+// recompute it and reject stale timestamps to stop replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostSigned posts a JSON body to url with the signature and event headers.
+// Any 2xx response counts as delivered; 410 Gone and private addresses are
+// permanent. client should come from NewWebhookClient.
+func PostSigned(ctx context.Context, client *http.Client, url, secret, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Deltra-Webhooks/1.0")
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := client.Do(req)
+	if errors.Is(err, ErrPrivateAddress) {
+		return 0, Permanent(err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return resp.StatusCode, nil
+	case resp.StatusCode == http.StatusGone:
+		return resp.StatusCode, Permanent(fmt.Errorf("webhook endpoint gone: %s", resp.Status))
+	default:
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// Webhook posts notifications to the user's notification webhook.
+type Webhook struct {
+	client *http.Client
+}
+
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	Title     string      `json:"title"`
+	Body      string      `json:"body"`
+	Data      models.JSON `json:"data,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func NewWebhook() *Webhook {
+	return &Webhook{client: NewWebhookClient()}
+}
+
+func (w *Webhook) Send(ctx context.Context, delivery models.NotificationDelivery, notification models.Notification) error {
+	// Look the secret up at send time so a rotated secret applies to
+	// retries too.
+	var webhook models.NotificationWebhook
+	if err := config.DB.Where("user_id = ?", delivery.UserID).First(&webhook).Error; err != nil {
+		return Permanent(errors.New("webhook removed"))
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		ID:        notification.ID,
+		Kind:      notification.Kind,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Data:      notification.Data,
+		CreatedAt: notification.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = PostSigned(ctx, w.client, webhook.URL, webhook.Secret, "notification."+notification.Kind, delivery.ID, body)
+	return err
+}
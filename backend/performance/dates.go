@@ -0,0 +1,19 @@
+package performance
+
+import "time"
+
+// Day truncates t to midnight UTC of the same calendar date.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func TradingDays(from, to time.Time) []time.Time {
+	var days []time.Time
+	for day := Day(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
@@ -0,0 +1,157 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+var ErrNoSolution = errors.New("irr did not converge")
+
+// Flow is an external cash flow into (positive) or out of (negative) a
+// portfolio.
+type Flow struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+type Valuation struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+type Point struct {
+	Date            time.Time `json:"date"`
+	Value           float64   `json:"value"`
+	Return          float64   `json:"return"`
+	BenchmarkReturn *float64  `json:"benchmark_return,omitempty"`
+}
+
+// TimeWeightedSeries chains daily returns, treating flows as arriving at the
+// end of the day they occur so they don't count as performance. Valuations
+// must be sorted ascending.
+func TimeWeightedSeries(valuations []Valuation, flows []Flow) []Point {
+	points := make([]Point, 0, len(valuations))
+	growth := 1.0
+
+	for i, valuation := range valuations {
+		if i > 0 {
+			previous := valuations[i-1]
+			if previous.Value > 0 {
+				flow := flowsBetween(flows, previous.Date, valuation.Date)
+				growth *= (valuation.Value - flow) / previous.Value
+			}
+		}
+		points = append(points, Point{
+			Date:   valuation.Date,
+			Value:  valuation.Value,
+			Return: growth - 1,
+		})
+	}
+
+	return points
+}
+
+func TimeWeightedReturn(valuations []Valuation, flows []Flow) float64 {
+	series := TimeWeightedSeries(valuations, flows)
+	if len(series) == 0 {
+		return 0
+	}
+	return series[len(series)-1].Return
+}
+
+func Annualize(totalReturn float64, from, to time.Time) float64 {
+	years := to.Sub(from).Hours() / 24 / 365
+	if years <= 0 || totalReturn <= -1 {
+		return totalReturn
+	}
+	return math.Pow(1+totalReturn, 1/years) - 1
+}
+
+// MoneyWeightedReturn is the XIRR of investing the starting value, the
+// external flows, and liquidating at the ending value.
+func MoneyWeightedReturn(start, end Valuation, flows []Flow) (float64, error) {
+	cashflows := []Flow{{Date: start.Date, Amount: -start.Value}}
+	for _, flow := range flows {
+		if flow.Date.After(start.Date) && !flow.Date.After(end.Date) {
+			cashflows = append(cashflows, Flow{Date: flow.Date, Amount: -flow.Amount})
+		}
+	}
+	cashflows = append(cashflows, Flow{Date: end.Date, Amount: end.Value})
+
+	return XIRR(cashflows)
+}
+
+// XIRR solves for the annual rate at which the flows net to zero, using
+// Newton's method with a bisection fallback.
+func XIRR(flows []Flow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoSolution
+	}
+
+	sorted := append([]Flow(nil), flows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	first := sorted[0].Date
+	// Flows on a single day have no time to earn a rate over.
+	if !sorted[len(sorted)-1].Date.After(first) {
+		return 0, ErrNoSolution
+	}
+
+	npv := func(rate float64) (float64, float64) {
+		value, derivative := 0.0, 0.0
+		for _, flow := range sorted {
+			years := flow.Date.Sub(first).Hours() / 24 / 365
+			discount := math.Pow(1+rate, years)
+			value += flow.Amount / discount
+			derivative -= years * flow.Amount / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	rate := 0.1
+	for i := 0; i < 100; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < 1e-7 {
+			return rate, nil
+		}
+		if derivative == 0 {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		rate = next
+	}
+
+	low, high := -0.9999, 10.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	if lowValue*highValue > 0 {
+		return 0, ErrNoSolution
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		midValue, _ := npv(mid)
+		if math.Abs(midValue) < 1e-7 {
+			return mid, nil
+		}
+		if lowValue*midValue < 0 {
+			high = mid
+		} else {
+			low, lowValue = mid, midValue
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+func flowsBetween(flows []Flow, after, through time.Time) float64 {
+	total := 0.0
+	for _, flow := range flows {
+		if flow.Date.After(after) && !flow.Date.After(through) {
+			total += flow.Amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,254 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+func TestTimeWeightedReturn(t *testing.T) {
+	tests := []struct {
+		name       string
+		valuations []Valuation
+		flows      []Flow
+		want       float64
+	}{
+		{
+			name: "no flows",
+			valuations: []Valuation{
+				{date("2024-01-02"), 100},
+				{date("2024-01-03"), 110},
+				{date("2024-01-04"), 121},
+			},
+			want: 0.21,
+		},
+		{
+			name: "deposit is not performance",
+			valuations: []Valuation{
+				{date("2024-01-02"), 100},
+				{date("2024-01-03"), 160},
+				{date("2024-01-04"), 176},
+			},
+			flows: []Flow{{date("2024-01-03"), 50}},
+			want:  0.21,
+		},
+		{
+			name: "withdrawal is not a loss",
+			valuations: []Valuation{
+				{date("2024-01-02"), 200},
+				{date("2024-01-03"), 120},
+			},
+			flows: []Flow{{date("2024-01-03"), -100}},
+			want:  0.1,
+		},
+		{
+			name: "flows before the range are ignored",
+			valuations: []Valuation{
+				{date("2024-01-02"), 100},
+				{date("2024-01-03"), 90},
+			},
+			flows: []Flow{{date("2024-01-01"), 100}},
+			want:  -0.1,
+		},
+		{
+			name:       "single day",
+			valuations: []Valuation{{date("2024-01-02"), 100}},
+			want:       0,
+		},
+		{
+			name: "empty start is skipped",
+			valuations: []Valuation{
+				{date("2024-01-02"), 0},
+				{date("2024-01-03"), 100},
+				{date("2024-01-04"), 105},
+			},
+			flows: []Flow{{date("2024-01-03"), 100}},
+			want:  0.05,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TimeWeightedReturn(tt.valuations, tt.flows); !near(got, tt.want) {
+				t.Errorf("TimeWeightedReturn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnualize(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    float64
+		from, to time.Time
+		want     float64
+	}{
+		// 2022 and 2023 are both 365-day years.
+		{"two years", 0.21, date("2022-01-01"), date("2024-01-01"), 0.1},
+		{"one year", 0.1, date("2023-01-01"), date("2024-01-01"), 0.1},
+		{"same day", 0.05, date("2024-01-01"), date("2024-01-01"), 0.05},
+		{"total loss", -1, date("2023-01-01"), date("2024-01-01"), -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Annualize(tt.total, tt.from, tt.to)
+			if !near(got, tt.want) {
+				t.Errorf("Annualize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name    string
+		flows   []Flow
+		want    float64
+		wantErr error
+	}{
+		{
+			name: "one year at ten percent",
+			flows: []Flow{
+				{date("2023-01-01"), -1000},
+				{date("2024-01-01"), 1100},
+			},
+			want: 0.1,
+		},
+		{
+			// The example from the spreadsheet XIRR documentation.
+			name: "irregular flows",
+			flows: []Flow{
+				{date("2008-01-01"), -10000},
+				{date("2008-03-01"), 2750},
+				{date("2008-10-30"), 4250},
+				{date("2009-02-15"), 3250},
+				{date("2009-04-01"), 2750},
+			},
+			want: 0.373362535,
+		},
+		{
+			name: "unsorted input",
+			flows: []Flow{
+				{date("2024-01-01"), 1100},
+				{date("2023-01-01"), -1000},
+			},
+			want: 0.1,
+		},
+		{
+			name: "loss",
+			flows: []Flow{
+				{date("2023-01-01"), -1000},
+				{date("2024-01-01"), 500},
+			},
+			want: -0.5,
+		},
+		{
+			name: "same day",
+			flows: []Flow{
+				{date("2024-01-01"), -1000},
+				{date("2024-01-01"), 1000},
+			},
+			wantErr: ErrNoSolution,
+		},
+		{
+			name: "no sign change",
+			flows: []Flow{
+				{date("2023-01-01"), -1000},
+				{date("2024-01-01"), -100},
+			},
+			wantErr: ErrNoSolution,
+		},
+		{
+			name:    "single flow",
+			flows:   []Flow{{date("2023-01-01"), -1000}},
+			wantErr: ErrNoSolution,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XIRR(tt.flows)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("XIRR() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("XIRR() error = %v", err)
+			}
+			if !near(got, tt.want) {
+				t.Errorf("XIRR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyWeightedReturn(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end Valuation
+		flows      []Flow
+		want       float64
+		wantErr    error
+	}{
+		{
+			name:  "no flows",
+			start: Valuation{date("2023-01-01"), 1000},
+			end:   Valuation{date("2024-01-01"), 1100},
+			want:  0.1,
+		},
+		{
+			name:  "deposit at the end earns nothing",
+			start: Valuation{date("2023-01-01"), 1000},
+			end:   Valuation{date("2024-01-01"), 1600},
+			flows: []Flow{{date("2024-01-01"), 500}},
+			want:  0.1,
+		},
+		{
+			name:  "flows outside the range are ignored",
+			start: Valuation{date("2023-01-01"), 1000},
+			end:   Valuation{date("2024-01-01"), 1100},
+			flows: []Flow{{date("2022-06-01"), 500}, {date("2024-02-01"), 500}},
+			want:  0.1,
+		},
+		{
+			name:    "same day",
+			start:   Valuation{date("2024-01-01"), 1000},
+			end:     Valuation{date("2024-01-01"), 1000},
+			wantErr: ErrNoSolution,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MoneyWeightedReturn(tt.start, tt.end, tt.flows)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MoneyWeightedReturn() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MoneyWeightedReturn() error = %v", err)
+			}
+			if !near(got, tt.want) {
+				t.Errorf("MoneyWeightedReturn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package performance
+
+import (
+	"deltra-backend/models"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PriceFunc returns a symbol's closing price on a day.
+type PriceFunc func(symbol string, day time.Time) (float64, bool)
+
+// Ledger is what a portfolio's value on past days is rebuilt from. Snapshots
+// and the performance report both value portfolios through it, so they agree.
+type Ledger struct {
+	Stocks       []models.Stock
+	Transactions []models.CashTransaction
+	Dividends    []models.Dividend
+}
+
+type StockValue struct {
+	Stock             models.Stock
+	Shares            float64
+	Price             float64
+	MarketValue       float64
+	OpenCallLiability float64
+	CumulativePremium float64
+}
+
+type DayValue struct {
+	Date              time.Time
+	Cash              float64
+	StockValue        float64
+	OpenCallLiability float64
+	CumulativePremium float64
+	TotalValue        float64
+	Stocks            []StockValue
+}
+
+func LoadLedger(db *gorm.DB, portfolioID string) (Ledger, error) {
+	var ledger Ledger
+	if err := db.Where("portfolio_id = ?", portfolioID).
+		Preload("CoveredCalls").
+		Find(&ledger.Stocks).Error; err != nil {
+		return ledger, err
+	}
+	if err := db.Where("portfolio_id = ?", portfolioID).
+		Order("occurred_at ASC").
+		Find(&ledger.Transactions).Error; err != nil {
+		return ledger, err
+	}
+	if err := db.Where("portfolio_id = ? AND reinvested_at IS NOT NULL", portfolioID).
+		Find(&ledger.Dividends).Error; err != nil {
+		return ledger, err
+	}
+	return ledger, nil
+}
+
+// ImplicitlyFunded reports whether the portfolio never recorded a deposit.
+// Such portfolios are funded by their purchases: buying stock counts as a
+// contribution rather than spending cash.
+func (l Ledger) ImplicitlyFunded() bool {
+	for _, txn := range l.Transactions {
+		if txn.Type == "deposit" {
+			return false
+		}
+	}
+	return true
+}
+
+// ExternalFlows returns deposits and withdrawals, or the purchases standing
+// in for them in an implicitly funded portfolio.
+func (l Ledger) ExternalFlows() []Flow {
+	implicitlyFunded := l.ImplicitlyFunded()
+
+	var flows []Flow
+	for _, txn := range l.Transactions {
+		switch {
+		case txn.IsExternal():
+			flows = append(flows, Flow{Date: Day(txn.OccurredAt), Amount: txn.Amount})
+		case implicitlyFunded && txn.Type == "stock_purchase":
+			flows = append(flows, Flow{Date: Day(txn.OccurredAt), Amount: -txn.Amount})
+		}
+	}
+	return flows
+}
+
+// SharesOn rebuilds a stock's share count at the end of day from its current
+// count, taking back reinvested dividends paid and adding back shares
+// delivered on assignment after that day. Splits aren't undone because
+// market history is split-adjusted, so today's count matches its prices.
+func (l Ledger) SharesOn(stock models.Stock, day time.Time) float64 {
+	endOfDay := day.AddDate(0, 0, 1)
+	shares := stock.Shares
+
+	for _, dividend := range l.Dividends {
+		if dividend.StockID != stock.ID || dividend.ReinvestedAt == nil {
+			continue
+		}
+		paid := *dividend.ReinvestedAt
+		if dividend.PayDate != nil {
+			paid = *dividend.PayDate
+		}
+		if !paid.Before(endOfDay) {
+			shares -= dividend.ReinvestedShares
+		}
+	}
+
+	for _, call := range stock.CoveredCalls {
+		if !call.SharesDelivered {
+			continue
+		}
+		delivered := call.ExpirationDate
+		if call.AssignmentDate != nil {
+			delivered = *call.AssignmentDate
+		}
+		if !delivered.Before(endOfDay) {
+			shares += float64(call.SharesCovered)
+		}
+	}
+
+	return math.Max(shares, 0)
+}
+
+// ValueOn values the portfolio at the end of day. Open calls count against
+// it at their intrinsic value, since option prices aren't available.
+func (l Ledger) ValueOn(day time.Time, prices PriceFunc) DayValue {
+	endOfDay := day.AddDate(0, 0, 1)
+	implicitlyFunded := l.ImplicitlyFunded()
+	value := DayValue{Date: day}
+
+	for _, txn := range l.Transactions {
+		if implicitlyFunded && txn.Type == "stock_purchase" {
+			continue
+		}
+		if txn.OccurredAt.Before(endOfDay) {
+			value.Cash += txn.Amount
+		}
+	}
+
+	for _, stock := range l.Stocks {
+		if !stock.CreatedAt.Before(endOfDay) {
+			continue
+		}
+
+		price, ok := prices(stock.Symbol, day)
+		if !ok {
+			price = stock.Basis
+		}
+
+		stockValue := StockValue{
+			Stock:  stock,
+			Shares: l.SharesOn(stock, day),
+			Price:  price,
+		}
+		stockValue.MarketValue = price * stockValue.Shares
+
+		for _, call := range stock.CoveredCalls {
+			if !call.CreatedAt.Before(endOfDay) {
+				continue
+			}
+
+			stockValue.CumulativePremium += call.TotalPremium - call.OpenFees
+			if call.BuybackDate != nil && call.BuybackDate.Before(endOfDay) {
+				stockValue.CumulativePremium -= call.BuybackFees + call.BuybackCost()
+			}
+
+			if OpenOn(call, day) {
+				stockValue.OpenCallLiability += math.Max(0, price-call.StrikePrice) * float64(call.SharesCovered)
+			}
+		}
+
+		value.StockValue += stockValue.MarketValue
+		value.OpenCallLiability += stockValue.OpenCallLiability
+		value.CumulativePremium += stockValue.CumulativePremium
+		value.Stocks = append(value.Stocks, stockValue)
+	}
+
+	value.TotalValue = value.Cash + value.StockValue - value.OpenCallLiability
+	return value
+}
+
+// OpenOn reports whether a call was open at the end of day. Calls closed
+// without a recorded date are taken to have closed at expiration.
+func OpenOn(call models.CoveredCall, day time.Time) bool {
+	if call.Status == "pending" || call.ExpirationDate.Before(day) {
+		return false
+	}
+	if call.AssignmentDate != nil && !call.AssignmentDate.After(day) {
+		return false
+	}
+	if call.BuybackDate != nil && !call.BuybackDate.After(day) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package performance
+
+import (
+	"deltra-backend/models"
+	"testing"
+	"time"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestLedgerSharesOn(t *testing.T) {
+	stock := models.Stock{
+		ID:     "stock",
+		Shares: 110,
+		CoveredCalls: []models.CoveredCall{{
+			Status:          "assigned",
+			SharesCovered:   100,
+			SharesDelivered: true,
+			ExpirationDate:  date("2024-03-15"),
+			AssignmentDate:  timePtr(date("2024-03-08")),
+		}, {
+			// Expired calls never delivered anything.
+			Status:         "expired",
+			SharesCovered:  100,
+			ExpirationDate: date("2024-02-16"),
+		}},
+	}
+	ledger := Ledger{
+		Stocks: []models.Stock{stock},
+		Dividends: []models.Dividend{{
+			StockID:          "stock",
+			PayDate:          timePtr(date("2024-02-01")),
+			ReinvestedShares: 10,
+			ReinvestedAt:     timePtr(date("2024-02-01")),
+		}, {
+			// Not reinvested yet, so not in the current count either.
+			StockID:          "stock",
+			PayDate:          timePtr(date("2024-05-01")),
+			ReinvestedShares: 5,
+		}, {
+			StockID:          "other",
+			PayDate:          timePtr(date("2024-02-01")),
+			ReinvestedShares: 50,
+			ReinvestedAt:     timePtr(date("2024-02-01")),
+		}},
+	}
+
+	tests := []struct {
+		day  string
+		want float64
+	}{
+		{"2024-01-15", 200},
+		{"2024-02-01", 210},
+		{"2024-03-07", 210},
+		{"2024-03-08", 110},
+		{"2024-06-01", 110},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.day, func(t *testing.T) {
+			if got := ledger.SharesOn(stock, date(tt.day)); got != tt.want {
+				t.Errorf("SharesOn(%s) = %v, want %v", tt.day, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerValueOn(t *testing.T) {
+	stock := models.Stock{
+		ID:        "stock",
+		Symbol:    "ABC",
+		Shares:    100,
+		Basis:     50,
+		CreatedAt: date("2024-01-02"),
+		CoveredCalls: []models.CoveredCall{{
+			Status:         "active",
+			StrikePrice:    55,
+			SharesCovered:  100,
+			TotalPremium:   200,
+			OpenFees:       1,
+			ExpirationDate: date("2024-01-19"),
+			CreatedAt:      date("2024-01-03"),
+		}},
+	}
+	purchase := models.CashTransaction{Type: "stock_purchase", Amount: -5000, OccurredAt: date("2024-01-02")}
+	premium := models.CashTransaction{Type: "premium", Amount: 199, OccurredAt: date("2024-01-03")}
+	deposit := models.CashTransaction{Type: "deposit", Amount: 6000, OccurredAt: date("2024-01-01")}
+
+	prices := func(symbol string, day time.Time) (float64, bool) {
+		if day.Equal(date("2024-01-04")) {
+			return 60, true
+		}
+		return 0, false
+	}
+
+	tests := []struct {
+		name         string
+		transactions []models.CashTransaction
+		day          string
+		cash         float64
+		liability    float64
+		total        float64
+		flows        int
+	}{
+		{
+			name:         "implicitly funded purchases are contributions",
+			transactions: []models.CashTransaction{purchase, premium},
+			day:          "2024-01-04",
+			cash:         199,
+			liability:    500,
+			total:        199 + 6000 - 500,
+			flows:        1,
+		},
+		{
+			name:         "deposits fund purchases",
+			transactions: []models.CashTransaction{deposit, purchase, premium},
+			day:          "2024-01-04",
+			cash:         1199,
+			liability:    500,
+			total:        1199 + 6000 - 500,
+			flows:        1,
+		},
+		{
+			name:         "unpriced days fall back to basis",
+			transactions: []models.CashTransaction{deposit, purchase},
+			day:          "2024-01-02",
+			cash:         1000,
+			total:        1000 + 5000,
+			flows:        1,
+		},
+		{
+			name:         "expired calls carry no liability",
+			transactions: []models.CashTransaction{deposit, purchase, premium},
+			day:          "2024-01-22",
+			cash:         1199,
+			total:        1199 + 5000,
+			flows:        1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := Ledger{Stocks: []models.Stock{stock}, Transactions: tt.transactions}
+			value := ledger.ValueOn(date(tt.day), prices)
+
+			if !near(value.Cash, tt.cash) {
+				t.Errorf("Cash = %v, want %v", value.Cash, tt.cash)
+			}
+			if !near(value.OpenCallLiability, tt.liability) {
+				t.Errorf("OpenCallLiability = %v, want %v", value.OpenCallLiability, tt.liability)
+			}
+			if !near(value.TotalValue, tt.total) {
+				t.Errorf("TotalValue = %v, want %v", value.TotalValue, tt.total)
+			}
+			if flows := ledger.ExternalFlows(); len(flows) != tt.flows {
+				t.Errorf("ExternalFlows() returned %d flows, want %d", len(flows), tt.flows)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Package query parses the pagination, filter and sort parameters shared by
+// list endpoints and applies them to a gorm query.
+//
+//	?status=active,pending          comma-separated values match any
+//	?expiration_from=2025-01-01     date ranges are inclusive of both ends
+//	?sort=-expiration_date,symbol   "-" sorts descending
+//	?limit=50&cursor=...            cursor comes from X-Next-Cursor
+//
+// Bodies stay plain arrays; the total and next cursor are sent as headers.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 500
+
+	TotalCountHeader = "X-Total-Count"
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+const dateLayout = "2006-01-02"
+
+// Filter narrows a query from the request parameters it knows about and
+// ignores the rest. Errors are shown to the client.
+type Filter func(db *gorm.DB, values url.Values) (*gorm.DB, error)
+
+// Spec describes what a list endpoint accepts. Sorts maps the name clients
+// use to a column; only non-null columns belong there, since the cursor
+// compares against the last row's values.
+type Spec struct {
+	Sorts       map[string]string
+	DefaultSort string
+	Filters     []Filter
+}
+
+type sortField struct {
+	name   string
+	column string
+	desc   bool
+}
+
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+type List struct {
+	Limit int
+
+	spec   Spec
+	values url.Values
+	sort   string
+	fields []sortField
+	after  *cursor
+}
+
+// Parse validates the list parameters of a request against spec.
+func Parse(c *gin.Context, spec Spec) (*List, error) {
+	list := &List{
+		Limit:  DefaultLimit,
+		spec:   spec,
+		values: c.Request.URL.Query(),
+		sort:   c.DefaultQuery("sort", spec.DefaultSort),
+	}
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		list.Limit = min(parsed, MaxLimit)
+	}
+
+	for _, name := range splitList(list.sort) {
+		field := sortField{name: strings.TrimPrefix(name, "-"), desc: strings.HasPrefix(name, "-")}
+		column, ok := spec.Sorts[field.name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", field.name)
+		}
+		field.column = column
+		list.fields = append(list.fields, field)
+	}
+	// id breaks ties so every row has a unique position.
+	list.fields = append(list.fields, sortField{name: "id", column: "id"})
+
+	if value := c.Query("cursor"); value != "" {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		var after cursor
+		if err == nil {
+			err = json.Unmarshal(data, &after)
+		}
+		if err != nil || len(after.Values) != len(list.fields) {
+			return nil, errors.New("invalid cursor")
+		}
+		if after.Sort != list.sort {
+			return nil, errors.New("cursor was issued for a different sort")
+		}
+		list.after = &after
+	}
+
+	return list, nil
+}
+
+// Find loads one page into dest, a pointer to a slice of models, and sets
+// the total and next-cursor headers. The total counts every row matching
+// the filters, not just this page.
+func (l *List) Find(c *gin.Context, db *gorm.DB, dest any, preloads ...string) error {
+	filtered := db
+	for _, filter := range l.spec.Filters {
+		var err error
+		if filtered, err = filter(filtered, l.values); err != nil {
+			return &Error{err}
+		}
+	}
+	filtered = filtered.Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Model(dest).Count(&total).Error; err != nil {
+		return err
+	}
+
+	page := filtered
+	if l.after != nil {
+		page = page.Where(l.afterCondition())
+	}
+	for _, field := range l.fields {
+		order := field.column
+		if field.desc {
+			order += " DESC"
+		}
+		page = page.Order(order)
+	}
+	for _, preload := range preloads {
+		page = page.Preload(preload)
+	}
+	if err := page.Limit(l.Limit + 1).Find(dest).Error; err != nil {
+		return err
+	}
+
+	c.Header(TotalCountHeader, strconv.FormatInt(total, 10))
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= l.Limit {
+		return nil
+	}
+	rows.Set(rows.Slice(0, l.Limit))
+
+	next, err := l.nextCursor(db, dest, rows.Index(l.Limit-1))
+	if err != nil {
+		return err
+	}
+	c.Header(NextCursorHeader, next)
+	return nil
+}
+
+// afterCondition expands the keyset comparison so mixed sort directions
+// work: (a > x) OR (a = x AND b < y) OR ...
+func (l *List) afterCondition() clause.Expr {
+	var clauses []string
+	var args []any
+
+	for i, field := range l.fields {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, l.fields[j].column+" = ?")
+			args = append(args, l.after.Values[j])
+		}
+		op := " > ?"
+		if field.desc {
+			op = " < ?"
+		}
+		parts = append(parts, field.column+op)
+		args = append(args, l.after.Values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return gorm.Expr(strings.Join(clauses, " OR "), args...)
+}
+
+func (l *List) nextCursor(db *gorm.DB, dest any, row reflect.Value) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(dest); err != nil {
+		return "", err
+	}
+
+	next := cursor{Sort: l.sort}
+	for _, field := range l.fields {
+		schemaField := stmt.Schema.LookUpField(field.column)
+		if schemaField == nil {
+			return "", fmt.Errorf("query: %s is not a column of %s", field.column, stmt.Schema.Name)
+		}
+		value, _ := schemaField.ValueOf(stmt.Context, row)
+		next.Values = append(next.Values, value)
+	}
+
+	data, err := json.Marshal(next)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Error wraps a problem with the request's filters, as opposed to a
+// database failure.
+type Error struct {
+	err error
+}
+
+func (e *Error) Error() string { return e.err.Error() }
+
+// Equals matches column against one or more comma-separated values.
+func Equals(param, column string) Filter {
+	return func(db *gorm.DB, values url.Values) (*gorm.DB, error) {
+		list := splitList(values.Get(param))
+		if len(list) == 0 {
+			return db, nil
+		}
+		return db.Where(column+" IN ?", list), nil
+	}
+}
+
+// Custom hands the comma-separated values of param to apply.
+func Custom(param string, apply func(db *gorm.DB, list []string) *gorm.DB) Filter {
+	return func(db *gorm.DB, values url.Values) (*gorm.DB, error) {
+		list := splitList(values.Get(param))
+		if len(list) == 0 {
+			return db, nil
+		}
+		return apply(db, list), nil
+	}
+}
+
+// DateRange reads param_from and param_to. Plain dates cover the whole day;
+// RFC 3339 timestamps are used as given.
+func DateRange(param, column string) Filter {
+	return func(db *gorm.DB, values url.Values) (*gorm.DB, error) {
+		if value := values.Get(param + "_from"); value != "" {
+			from, _, err := parseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s_from must be YYYY-MM-DD or RFC 3339", param)
+			}
+			db = db.Where(column+" >= ?", from)
+		}
+		if value := values.Get(param + "_to"); value != "" {
+			to, dateOnly, err := parseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s_to must be YYYY-MM-DD or RFC 3339", param)
+			}
+			if dateOnly {
+				db = db.Where(column+" < ?", to.AddDate(0, 0, 1))
+			} else {
+				db = db.Where(column+" <= ?", to)
+			}
+		}
+		return db, nil
+	}
+}
+
+func parseTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package query
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+var testSpec = Spec{
+	Sorts: map[string]string{
+		"rank": "rank",
+		"name": "name",
+	},
+	DefaultSort: "rank",
+	Filters: []Filter{
+		Equals("name", "name"),
+		DateRange("created", "created_at"),
+	},
+}
+
+func testContext(rawQuery string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("GET", "/items?"+rawQuery, nil)
+	return c, recorder
+}
+
+// dryRun builds SQL without a database connection.
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type pageRow struct {
+	ID   string `gorm:"primaryKey"`
+	Rank int    `gorm:"not null"`
+	Name string `gorm:"not null"`
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantErr   string
+		wantLimit int
+		wantSort  []string
+	}{
+		{name: "defaults", wantLimit: DefaultLimit, wantSort: []string{"rank", "id"}},
+		{name: "limit is capped", query: "limit=10000", wantLimit: MaxLimit, wantSort: []string{"rank", "id"}},
+		{name: "zero limit", query: "limit=0", wantErr: "limit must be a positive integer"},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: "limit must be a positive integer"},
+		{name: "mixed directions", query: "sort=-rank,name", wantLimit: DefaultLimit, wantSort: []string{"-rank", "name", "id"}},
+		{name: "unknown sort", query: "sort=password", wantErr: `cannot sort by "password"`},
+		{name: "garbage cursor", query: "cursor=!!!", wantErr: "invalid cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			list, err := Parse(c, testSpec)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if list.Limit != tt.wantLimit {
+				t.Errorf("Limit = %d, want %d", list.Limit, tt.wantLimit)
+			}
+			var sort []string
+			for _, field := range list.fields {
+				name := field.name
+				if field.desc {
+					name = "-" + name
+				}
+				sort = append(sort, name)
+			}
+			if strings.Join(sort, ",") != strings.Join(tt.wantSort, ",") {
+				t.Errorf("sort fields = %v, want %v", sort, tt.wantSort)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	db := dryRun(t)
+	c, _ := testContext("sort=-rank,name")
+	list, err := Parse(c, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []pageRow{{ID: "a", Rank: 3, Name: "x"}, {ID: "b", Rank: 2, Name: "y"}}
+	next, err := list.nextCursor(db, &rows, reflect.ValueOf(rows).Index(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same sort", func(t *testing.T) {
+		c, _ := testContext("sort=-rank,name&cursor=" + url.QueryEscape(next))
+		list, err := Parse(c, testSpec)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		want := []any{float64(2), "y", "b"}
+		if fmt.Sprint(list.after.Values) != fmt.Sprint(want) {
+			t.Errorf("cursor values = %v, want %v", list.after.Values, want)
+		}
+	})
+
+	t.Run("different sort", func(t *testing.T) {
+		c, _ := testContext("sort=name&cursor=" + url.QueryEscape(next))
+		if _, err := Parse(c, testSpec); err == nil {
+			t.Fatal("Parse() accepted a cursor issued for another sort")
+		}
+	})
+}
+
+func TestAfterCondition(t *testing.T) {
+	list := &List{
+		fields: []sortField{
+			{name: "rank", column: "rank", desc: true},
+			{name: "name", column: "name"},
+			{name: "id", column: "id"},
+		},
+		after: &cursor{Values: []any{2, "y", "b"}},
+	}
+
+	db := dryRun(t)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&pageRow{}).Where(list.afterCondition()).Find(&[]pageRow{})
+	})
+
+	want := `(rank < 2) OR (rank = 2 AND name > 'y') OR (rank = 2 AND name = 'y' AND id > 'b')`
+	if !strings.Contains(sql, want) {
+		t.Errorf("SQL = %s\nwant it to contain %s", sql, want)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "no filters"},
+		{name: "equals splits values", query: "name=a,+b", want: []string{`name IN ('a','b')`}},
+		{
+			name:  "plain dates cover the whole day",
+			query: "created_from=2024-01-01&created_to=2024-01-31",
+			want:  []string{`created_at >= '2024-01-01 00:00:00'`, `created_at < '2024-02-01 00:00:00'`},
+		},
+		{
+			name:  "timestamps are used as given",
+			query: "created_to=2024-01-31T12:00:00Z",
+			want:  []string{`created_at <= '2024-01-31 12:00:00'`},
+		},
+		{name: "bad date", query: "created_from=yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			db := dryRun(t).Model(&pageRow{})
+			for _, filter := range testSpec.Filters {
+				if db, err = filter(db, values); err != nil {
+					break
+				}
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("filter accepted an invalid value")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB { return tx.Find(&[]pageRow{}) })
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Errorf("SQL = %s\nwant it to contain %s", sql, want)
+				}
+			}
+		})
+	}
+}
+
+// TestFindPages walks every page of a table with duplicate sort values
+// against a real database when TEST_DATABASE_URL is set.
+func TestFindPages(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := db.Table("query_test_rows")
+	if err := table.Migrator().CreateTable(&pageRow{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Migrator().DropTable("query_test_rows") })
+
+	var rows []pageRow
+	for i := 0; i < 23; i++ {
+		rows = append(rows, pageRow{ID: fmt.Sprintf("row-%02d", i), Rank: i % 4, Name: fmt.Sprintf("n%d", i%3)})
+	}
+	if err := db.Table("query_test_rows").Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sort := range []string{"rank", "-rank,name", "name,-rank"} {
+		t.Run(sort, func(t *testing.T) {
+			seen := map[string]bool{}
+			next := ""
+			for pages := 0; ; pages++ {
+				if pages > len(rows) {
+					t.Fatal("pagination did not terminate")
+				}
+				rawQuery := "limit=5&sort=" + url.QueryEscape(sort)
+				if next != "" {
+					rawQuery += "&cursor=" + url.QueryEscape(next)
+				}
+				c, recorder := testContext(rawQuery)
+				list, err := Parse(c, testSpec)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var page []pageRow
+				if err := list.Find(c, db.Table("query_test_rows"), &page); err != nil {
+					t.Fatal(err)
+				}
+				if got := recorder.Header().Get(TotalCountHeader); got != fmt.Sprint(len(rows)) {
+					t.Fatalf("%s = %s, want %d", TotalCountHeader, got, len(rows))
+				}
+				for _, row := range page {
+					if seen[row.ID] {
+						t.Fatalf("row %s returned twice", row.ID)
+					}
+					seen[row.ID] = true
+				}
+
+				next = recorder.Header().Get(NextCursorHeader)
+				if next == "" {
+					break
+				}
+			}
+			if len(seen) != len(rows) {
+				t.Errorf("saw %d rows, want %d", len(seen), len(rows))
+			}
+		})
+	}
+}
@@ -2,30 +2,75 @@ package routes
 
 import (
 	"deltra-backend/controllers"
+	"deltra-backend/metrics"
 	"deltra-backend/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
+	r.GET("/healthz", controllers.Healthz)
+	r.GET("/readyz", controllers.Readyz)
+	r.GET("/metrics", metrics.Handler())
+
 	api := r.Group("/v1")
 
 	auth := api.Group("/auth")
 	{
 		auth.POST("/oauth", controllers.CreateOrFindOAuthUser)
+		auth.POST("/refresh", controllers.RefreshSession)
+		auth.POST("/logout", controllers.Logout)
 	}
 
+	api.GET("/shared/:token", controllers.GetSharedPortfolio)
+	api.GET("/calendar/:token", controllers.GetCalendarFeed)
+
 	api.Use(middleware.AuthMiddleware())
 	{
-		api.GET("/profile", controllers.GetProfile)
+		me := api.Group("/me")
+		{
+			me.GET("", controllers.GetMe)
+			me.PATCH("", controllers.UpdateMe)
+			me.DELETE("", controllers.DeleteMe)
+			me.POST("/deletion", controllers.RequestAccountDeletion)
+		}
 
-		users := api.Group("/users")
+		sessions := api.Group("/sessions", middleware.RequireSession())
+		{
+			sessions.GET("", controllers.GetSessions)
+			sessions.DELETE("", controllers.RevokeOtherSessions)
+			sessions.DELETE("/:sessionId", controllers.RevokeSession)
+		}
+
+		apiKeys := api.Group("/api-keys", middleware.RequireSession())
+		{
+			apiKeys.GET("", controllers.GetAPIKeys)
+			apiKeys.POST("", controllers.CreateAPIKey)
+			apiKeys.DELETE("/:keyId", controllers.RevokeAPIKey)
+		}
+
+		invitations := api.Group("/invitations")
+		{
+			invitations.GET("", controllers.GetInvitations)
+			invitations.POST("/:invitationId/accept", middleware.RequireSession(), controllers.AcceptInvitation)
+			invitations.POST("/:invitationId/decline", middleware.RequireSession(), controllers.DeclineInvitation)
+		}
+
+		identities := api.Group("/identities")
 		{
-			users.POST("", controllers.AddUser)
+			identities.GET("", controllers.GetIdentities)
+			identities.POST("", controllers.LinkIdentity)
+			identities.POST("/merge", controllers.MergeAccount)
+			identities.DELETE("/:identityId", controllers.UnlinkIdentity)
+		}
 
-			user := users.Group("/:id")
+		users := api.Group("/users")
+		{
+			user := users.Group("/:id", middleware.RequireSelf())
 			{
 				user.GET("", controllers.GetUser)
+				user.GET("/stream", controllers.StreamEvents)
 
 				portfolios := user.Group("/portfolios")
 				{
@@ -36,6 +81,30 @@ func SetupRoutes(r *gin.Engine) {
 					{
 						portfolio.PATCH("", controllers.UpdatePortfolio)
 						portfolio.DELETE("", controllers.DeletePortfolio)
+						portfolio.POST("/restore", controllers.RestorePortfolio)
+						portfolio.GET("/fee-schedule", controllers.GetFeeSchedule)
+						portfolio.PUT("/fee-schedule", controllers.UpdateFeeSchedule)
+						portfolio.GET("/cash", controllers.GetCashTransactions)
+						portfolio.POST("/cash", controllers.CreateCashTransaction)
+						portfolio.GET("/summary", controllers.GetPortfolioSummary)
+						portfolio.GET("/performance", controllers.GetPortfolioPerformance)
+						portfolio.GET("/equity-curve", controllers.GetEquityCurve)
+						portfolio.POST("/snapshots/backfill", controllers.BackfillSnapshots)
+
+						members := portfolio.Group("/members")
+						{
+							members.GET("", controllers.GetPortfolioMembers)
+							members.POST("", controllers.InvitePortfolioMember)
+							members.PATCH("/:memberId", controllers.UpdatePortfolioMember)
+							members.DELETE("/:memberId", controllers.RemovePortfolioMember)
+						}
+
+						shareLinks := portfolio.Group("/share-links")
+						{
+							shareLinks.GET("", controllers.GetShareLinks)
+							shareLinks.POST("", controllers.CreateShareLink)
+							shareLinks.DELETE("/:linkId", controllers.RevokeShareLink)
+						}
 					}
 				}
 
@@ -49,12 +118,21 @@ func SetupRoutes(r *gin.Engine) {
 						stock.GET("", controllers.GetStock)
 						stock.PATCH("", controllers.UpdateStock)
 						stock.DELETE("", controllers.DeleteStock)
+						stock.POST("/restore", controllers.RestoreStock)
+						stock.GET("/snapshots", controllers.GetStockSnapshots)
 
 						stockCalls := stock.Group("/covered-calls")
 						{
 							stockCalls.GET("", controllers.GetStockCoveredCalls)
 							stockCalls.POST("", controllers.CreateStockCoveredCall)
 						}
+
+						dividends := stock.Group("/dividends")
+						{
+							dividends.GET("", controllers.GetDividends)
+							dividends.POST("", controllers.CreateDividend)
+							dividends.DELETE("/:dividendId", controllers.DeleteDividend)
+						}
 					}
 				}
 
@@ -68,9 +146,77 @@ func SetupRoutes(r *gin.Engine) {
 						call.GET("", controllers.GetCoveredCall)
 						call.PATCH("", controllers.UpdateCoveredCall)
 						call.DELETE("", controllers.DeleteCoveredCall)
+						call.POST("/restore", controllers.RestoreCoveredCall)
 						call.POST("/activate", controllers.ActivateCoveredCall)
 					}
 				}
+
+				user.GET("/dividends/assignment-risk", controllers.GetDividendAssignmentRisks)
+				user.GET("/trash", controllers.GetTrash)
+
+				alerts := user.Group("/alerts")
+				{
+					alerts.GET("", controllers.GetAlertEvents)
+					alerts.GET("/rules", controllers.GetAlertRules)
+					alerts.POST("/rules", controllers.CreateAlertRule)
+					alerts.PATCH("/rules/:ruleId", controllers.UpdateAlertRule)
+					alerts.DELETE("/rules/:ruleId", controllers.DeleteAlertRule)
+				}
+
+				notifications := user.Group("/notifications")
+				{
+					notifications.GET("", controllers.GetNotifications)
+					notifications.POST("/read", controllers.MarkAllNotificationsRead)
+					notifications.POST("/test", controllers.SendTestNotification)
+					notifications.POST("/:notificationId/read", controllers.MarkNotificationRead)
+					notifications.GET("/webhook", controllers.GetNotificationWebhook)
+					notifications.PUT("/webhook", controllers.SetNotificationWebhook)
+					notifications.DELETE("/webhook", controllers.DeleteNotificationWebhook)
+				}
+
+				webhooks := user.Group("/webhooks")
+				{
+					webhooks.GET("", controllers.GetWebhookEndpoints)
+					webhooks.POST("", controllers.CreateWebhookEndpoint)
+					webhooks.PATCH("/:webhookId", controllers.UpdateWebhookEndpoint)
+					webhooks.DELETE("/:webhookId", controllers.DeleteWebhookEndpoint)
+					webhooks.GET("/:webhookId/deliveries", controllers.GetWebhookDeliveries)
+					webhooks.POST("/:webhookId/deliveries/:deliveryId/replay", controllers.ReplayWebhookDelivery)
+				}
+
+				devices := user.Group("/devices")
+				{
+					devices.GET("", controllers.GetPushDevices)
+					devices.POST("", controllers.RegisterPushDevice)
+					devices.DELETE("/:deviceId", controllers.DeletePushDevice)
+				}
+
+				calendar := user.Group("/calendar")
+				{
+					calendar.GET("", controllers.GetCalendar)
+					calendar.GET("/ics", controllers.GetCalendarICS)
+					calendar.POST("/feed", controllers.CreateCalendarFeed)
+					calendar.DELETE("/feed", controllers.DeleteCalendarFeed)
+				}
+
+				audit := user.Group("/audit")
+				{
+					audit.GET("", controllers.GetAuditEvents)
+					audit.POST("/:eventId/revert", controllers.RevertAuditEvent)
+				}
+
+				corporateActions := user.Group("/corporate-actions")
+				{
+					corporateActions.GET("", controllers.GetCorporateActions)
+					corporateActions.POST("", controllers.CreateCorporateAction)
+
+					action := corporateActions.Group("/:actionId")
+					{
+						action.GET("", controllers.GetCorporateAction)
+						action.DELETE("", controllers.DeleteCorporateAction)
+						action.POST("/apply", controllers.ApplyCorporateAction)
+					}
+				}
 			}
 		}
 	}
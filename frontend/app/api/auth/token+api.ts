@@ -14,6 +14,7 @@ import {
 } from '@/constants';
 
 async function createOrFindUser(oauthData: {
+  idToken: string;
   providerId: string;
   provider: string;
   email: string;
@@ -81,6 +82,7 @@ export async function POST(request: Request) {
 
   try {
     const backendUser = await createOrFindUser({
+      idToken: data.id_token,
       providerId: sub,
       provider: 'google',
       email: (userInfo as any).email,